@@ -3,6 +3,11 @@ package cmd
 // Import to auto-load subcommands
 import (
 	_ "github.com/tvs/ultravisor/cmd/get"
+	_ "github.com/tvs/ultravisor/cmd/inspect"
 	_ "github.com/tvs/ultravisor/cmd/load"
+	_ "github.com/tvs/ultravisor/cmd/pin"
+	_ "github.com/tvs/ultravisor/cmd/save"
+	_ "github.com/tvs/ultravisor/cmd/sync"
+	_ "github.com/tvs/ultravisor/cmd/unload"
 	_ "github.com/tvs/ultravisor/cmd/version"
 )
@@ -0,0 +1,166 @@
+package get
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	pload "github.com/tvs/ultravisor/pkg/load"
+	"github.com/tvs/ultravisor/pkg/util/output"
+)
+
+var getImagesCmd = &cobra.Command{
+	Use:     "images",
+	Aliases: []string{"image", "img"},
+	Short:   "list the images on the vSphere IaaS Control Plane VMs",
+	Long: `list the container images across the vSphere IaaS Control Plane's control plane
+VMs, showing which VMs have each image. Images missing from some of the VMs are
+highlighted.`,
+	Example: "  get images\n" +
+		"  get images --partial\n" +
+		"  get images --loaded\n" +
+		"  get images --output json",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		l := zerolog.Ctx(cmd.Context())
+
+		if o := getImagesCmdArgs.Output; o != "table" && o != "json" {
+			l.Error().Str("output", o).Msg("Invalid output format, must be table or json")
+			root.SetExitCode(1)
+			return
+		}
+
+		opts := pload.ImagesOptions{
+			Parallel:  getImagesCmdArgs.Parallel,
+			Runtime:   getImagesCmdArgs.Runtime,
+			Namespace: getImagesCmdArgs.Namespace,
+		}
+
+		results, err := pload.ListImages(cmd.Context(), opts)
+		for _, r := range results {
+			if r.Err != nil {
+				l.Error().Err(r.Err).Str("address", r.VM).Msg("failed to list images")
+			}
+		}
+
+		var pErr error
+		switch json := getImagesCmdArgs.Output == "json"; {
+		case getImagesCmdArgs.Loaded && json:
+			pErr = output.JSON(os.Stdout, loadedImages(results))
+		case getImagesCmdArgs.Loaded:
+			pErr = printLoaded(os.Stdout, loadedImages(results))
+		default:
+			inv := pload.NewInventory(results)
+			if getImagesCmdArgs.Partial {
+				inv.Images = inv.Partial()
+			}
+
+			if json {
+				pErr = output.JSON(os.Stdout, inv)
+			} else {
+				pErr = printInventory(os.Stdout, inv, isatty.IsTerminal(os.Stdout.Fd()))
+			}
+		}
+		if pErr != nil {
+			l.Error().Err(pErr).Msg("unable to print images")
+			root.SetExitCode(1)
+		}
+
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to list images on vSphere IaaS Control Plane VMs")
+			root.SetExitCode(1)
+		}
+	},
+}
+
+// loadedImages filters the images down to those loaded by ultravisor.
+func loadedImages(results []pload.VMImages) []pload.VMImages {
+	filtered := make([]pload.VMImages, len(results))
+	for i, r := range results {
+		filtered[i] = pload.VMImages{VM: r.VM, Err: r.Err}
+		for _, img := range r.Images {
+			if _, ok := img.Provenance(); ok {
+				filtered[i].Images = append(filtered[i].Images, img)
+			}
+		}
+	}
+
+	return filtered
+}
+
+// printInventory writes a table of the images across every VM, one row per
+// reference. Images missing from some VMs are marked, and drawn in yellow when
+// color is set.
+func printInventory(w io.Writer, inv pload.Inventory, color bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REFERENCE\tDIGEST\tSIZE\tPINNED\tVMS\tMISSING")
+
+	for _, img := range inv.Images {
+		missing := "-"
+		if img.Partial {
+			missing = strings.Join(inv.Missing(img), ",")
+		}
+
+		digest := img.Digest
+		if digest == "" {
+			digest = "-"
+		}
+
+		for _, ref := range img.References {
+			row := fmt.Sprintf("%s\t%s\t%s\t%t\t%d/%d\t%s", ref, digest, img.Size, img.Pinned, len(img.VMs), len(inv.VMs), missing)
+			if img.Partial && color {
+				// Color each cell so the escapes don't skew the column widths
+				row = "\x1b[33m" + strings.ReplaceAll(row, "\t", "\x1b[0m\t\x1b[33m") + "\x1b[0m"
+			}
+			fmt.Fprintln(tw, row)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// printLoaded writes a table of the provenance of the images loaded by
+// ultravisor on every VM.
+func printLoaded(w io.Writer, results []pload.VMImages) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VM\tREFERENCE\tLOADED BY\tLOADED FROM\tLOADED AT\tSOURCE\tSOURCE DIGEST\tVERSION")
+
+	for _, r := range results {
+		for _, img := range r.Images {
+			p, _ := img.Provenance()
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.VM, img.Reference, p.LoadedBy, p.LoadedFrom, p.LoadedAt, p.Source, p.SourceDigest, p.Version)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// getImagesCmdArgs holds the flags defined for the get images command
+var getImagesCmdArgs struct {
+	Parallel  int
+	Loaded    bool
+	Partial   bool
+	Output    string
+	Runtime   string
+	Namespace string
+}
+
+func init() {
+	getImagesCmd.Flags().IntVar(&getImagesCmdArgs.Parallel, "parallel", 3, "maximum number of VMs to list concurrently, 0 for all at once")
+	getImagesCmd.Flags().BoolVar(&getImagesCmdArgs.Loaded, "loaded", false, "list only images loaded by ultravisor, with who loaded them, from where and when")
+	getImagesCmd.Flags().BoolVar(&getImagesCmdArgs.Partial, "partial", false, "list only images missing from some of the VMs")
+	getImagesCmd.MarkFlagsMutuallyExclusive("loaded", "partial")
+	getImagesCmd.Flags().StringVarP(&getImagesCmdArgs.Output, "output", "o", "table", "output format: table or json")
+
+	getImagesCmd.Flags().StringVar(&getImagesCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime to list, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+	getImagesCmd.Flags().StringVar(&getImagesCmdArgs.Namespace, "namespace", "", "containerd namespace to list; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
+	getCmd.AddCommand(getImagesCmd)
+}
@@ -0,0 +1,41 @@
+package inspect
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/util/output"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [archive]",
+	Short: "inspect an image archive",
+	Long: `inspects a docker-archive or OCI layout tarball, listing the image references
+and digests it contains as they will appear once loaded`,
+	Example: "  inspect container.tar",
+
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		l := zerolog.Ctx(cmd.Context())
+
+		a, err := archive.Inspect(args[0])
+		if err != nil {
+			l.Error().Err(err).Str("file", args[0]).Msg("unable to inspect archive")
+			root.SetExitCode(1)
+			return
+		}
+
+		if err := output.JSON(os.Stdout, a); err != nil {
+			l.Error().Err(err).Msg("unable to marshal archive into json")
+			root.SetExitCode(1)
+		}
+	},
+}
+
+func init() {
+	root.Cmd().AddCommand(inspectCmd)
+}
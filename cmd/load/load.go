@@ -1,30 +1,387 @@
 package load
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 
 	"github.com/tvs/ultravisor/cmd/root"
 	pload "github.com/tvs/ultravisor/pkg/load"
+	"github.com/tvs/ultravisor/pkg/util/output"
 )
 
 var loadCmd = &cobra.Command{
-	Use:   "load [container]",
-	Short: "load a container into the vSphere IaaS Control Plane",
-	Long:  `loads a container into each of the vSphere IaaS Control Plane's control plane VMs`,
+	Use:   "load [container...]",
+	Short: "load containers into the vSphere IaaS Control Plane",
+	Long: `loads containers into each of the vSphere IaaS Control Plane's control plane VMs.
+Containers may be given as files, directories of .tar files, or glob patterns.
+A container named - is read from stdin, docker://<image> is exported from the
+local Docker daemon, oci:<dir>[:tag] is read from an OCI image layout, and
+profile:<name>/<reference>[,<reference>...] is exported from the Supervisor of
+another profile. With --from-profile every argument is a reference exported
+from that profile's Supervisor.`,
+	Example: "  load container.tar\n" +
+		"  load controller.tar webhook.tar\n" +
+		"  load ./build/images\n" +
+		"  load './build/*.tar'\n" +
+		"  docker save myctrl:dev | load -\n" +
+		"  load docker://myctrl:dev\n" +
+		"  load oci:./build/layout:dev\n" +
+		"  load profile:lab/localhost:5000/vmware/foo:1.2.3\n" +
+		"  load --from-profile lab localhost:5000/vmware/foo:1.2.3 localhost:5000/vmware/bar:1.2.3\n" +
+		"  load container.tar --parallel 1\n" +
+		"  load container.tar --mode staged\n" +
+		"  load container.tar --mode delta\n" +
+		"  load container.tar --force\n" +
+		"  load container.tar --atomic\n" +
+		"  load foo.tar --tag foo:dev=localhost:5000/vmware/foo:1.2.3\n" +
+		"  load foo.tar --as localhost:5000/vmware/foo:1.2.3\n" +
+		"  load container.tar --pin\n" +
+		"  load container.tar --strategy relay\n" +
+		"  load container.tar --staging-dir /var/tmp\n" +
+		"  load container.tar --preflight-only\n" +
+		"  load container.tar --dry-run\n" +
+		"  load container.tar --dry-run -o json\n" +
+		"  load container.tar --compress zstd",
 
-	Args: cobra.ExactArgs(1),
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		l := zerolog.Ctx(cmd.Context())
-		container := args[0]
 
-		if err := pload.Load(cmd.Context(), container); err != nil {
-			l.Error().Err(err).Msg("Unable to load container to vSphere IaaS Control Plane VMs")
+		if o := loadCmdArgs.Output; o != "text" && o != "json" {
+			l.Error().Str("output", o).Msg("Invalid output format, must be text or json")
+			root.SetExitCode(1)
+			return
+		}
+
+		// docker can't label images after they're imported, so the images
+		// would be left on the VMs unpinned
+		if loadCmdArgs.Pin && loadCmdArgs.Runtime == pload.DockerRuntime {
+			l.Error().Msg("Images can't be pinned with the docker runtime")
+			root.SetExitCode(1)
+			return
+		}
+
+		if loadCmdArgs.FromProfile != "" {
+			args = []string{pload.ProfileSource(loadCmdArgs.FromProfile, args)}
+		}
+
+		containers, err := pload.ResolveContainers(args)
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to resolve containers")
+			root.SetExitCode(1)
+			return
+		}
+
+		var tags []pload.Tag
+		for _, t := range loadCmdArgs.Tags {
+			tag, err := pload.ParseTag(t)
+			if err != nil {
+				l.Error().Err(err).Msg("Invalid tag")
+				root.SetExitCode(1)
+				return
+			}
+			tags = append(tags, tag)
+		}
+
+		opts := pload.Options{
+			Parallel:   loadCmdArgs.Parallel,
+			Mode:       pload.Mode(loadCmdArgs.Mode),
+			Force:      loadCmdArgs.Force,
+			Atomic:     loadCmdArgs.Atomic,
+			Tags:       tags,
+			As:         loadCmdArgs.As,
+			Pin:        loadCmdArgs.Pin,
+			Strategy:   pload.Strategy(loadCmdArgs.Strategy),
+			Compress:   pload.Compression(loadCmdArgs.Compress),
+			StagingDir: loadCmdArgs.StagingDir,
+			Runtime:    loadCmdArgs.Runtime,
+			Namespace:  loadCmdArgs.Namespace,
+			Stdin:      cmd.InOrStdin(),
+		}
+
+		// Bars are drawn on stderr so they don't mix with the tables on
+		// stdout, and logs are written through them so they don't garble
+		// the bars when both are on the terminal
+		if isatty.IsTerminal(os.Stderr.Fd()) && !root.JSON() {
+			bars := newProgressBars(os.Stderr)
+			opts.Progress = bars.update
+
+			logger := l.Output(bars.logWriter(root.LogOutput()))
+			l = &logger
+			cmd.SetContext(logger.WithContext(cmd.Context()))
+		} else {
+			opts.Progress = progressLogger(l)
+		}
+
+		if loadCmdArgs.DryRun {
+			plan, err := pload.Plan(cmd.Context(), containers, opts)
+			if err != nil {
+				l.Error().Err(err).Msg("Unable to plan load")
+				root.SetExitCode(1)
+				return
+			}
+
+			if loadCmdArgs.Output == "json" {
+				err = output.JSON(os.Stdout, plan)
+			} else {
+				err = printPlan(os.Stdout, plan)
+			}
+			if err != nil {
+				l.Error().Err(err).Msg("unable to print plan")
+				root.SetExitCode(1)
+			}
+
+			if !pload.Go(plan.Preflight) {
+				l.Error().Msg("Preflight checks did not pass, the load would not proceed")
+				root.SetExitCode(1)
+			}
+			return
+		}
+
+		if loadCmdArgs.PreflightOnly {
+			report, err := pload.Preflight(cmd.Context(), containers, opts)
+			if report != nil {
+				if err := printPreflight(os.Stdout, report); err != nil {
+					l.Error().Err(err).Msg("unable to print preflight report")
+				}
+			}
+
+			if err != nil {
+				l.Error().Err(err).Msg("Preflight checks did not pass")
+				root.SetExitCode(1)
+			}
+			return
+		}
+
+		results, err := pload.Load(cmd.Context(), containers, opts)
+
+		var pfErr *pload.PreflightError
+		if errors.As(err, &pfErr) {
+			if err := printPreflight(os.Stdout, pfErr.Results); err != nil {
+				l.Error().Err(err).Msg("unable to print preflight report")
+			}
+		}
+
+		var loaded, skipped, failed int
+		for _, r := range results {
+			if r.RolledBack {
+				l.Warn().Str("file", r.Container).Str("address", r.VM).Msg("rolled back to previous images")
+			}
+
+			if r.Err != nil {
+				failed++
+				l.Error().Err(r.Err).Str("file", r.Container).Str("address", r.VM).Dur("duration", r.Duration).Msg("failed to load container")
+				continue
+			}
+
+			if r.Skipped {
+				skipped++
+				l.Info().Str("file", r.Container).Str("address", r.VM).Dur("duration", r.Duration).Msg("container already present, skipped")
+				continue
+			}
+
+			loaded++
+			l.Info().Str("file", r.Container).Str("address", r.VM).Dur("duration", r.Duration).Any("imported", r.Imported).Msg("loaded container")
+		}
+
+		if len(results) > 0 {
+			l.Info().Int("containers", len(containers)).Int("loaded", loaded).Int("skipped", skipped).Int("failed", failed).Msg("load summary")
+		}
+
+		if err := printVerifications(os.Stdout, results, loadCmdArgs.Pin); err != nil {
+			l.Error().Err(err).Msg("unable to print verification results")
+		}
+
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to load containers to vSphere IaaS Control Plane VMs")
 			root.SetExitCode(1)
 		}
 	},
 }
 
+// printVerifications writes a table of the verification results of every VM,
+// including whether each reference is pinned if requested.
+func printVerifications(w io.Writer, results []pload.Result, pins bool) error {
+	var rows []output.VerificationRow
+	for _, r := range results {
+		for _, v := range r.Verifications {
+			rows = append(rows, output.VerificationRow{
+				Origin:    filepath.Base(r.Container),
+				VM:        r.VM,
+				Reference: v.Reference,
+				Expected:  v.Expected,
+				Actual:    v.Actual,
+				Status:    v.Status(),
+				Pinned:    v.Pinned,
+			})
+		}
+	}
+
+	return output.Verifications(w, "CONTAINER", rows, pins)
+}
+
+// printPreflight writes a table of the preflight checks of every VM, followed
+// by the overall go/no-go decision.
+func printPreflight(w io.Writer, report []pload.PreflightResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VM\tCHECK\tSTATUS\tDETAIL")
+
+	for _, r := range report {
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.VM, "connect", pload.FailStatus, r.Err)
+			continue
+		}
+
+		for _, c := range r.Checks {
+			detail := c.Detail
+			if detail == "" {
+				detail = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.VM, c.Name, c.Status, detail)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	decision := "GO"
+	if !pload.Go(report) {
+		decision = "NO-GO"
+	}
+	_, err := fmt.Fprintf(w, "\n%s: %d VMs checked\n", decision, len(report))
+	return err
+}
+
+// printPlan writes a human readable description of the plan.
+func printPlan(w io.Writer, plan *pload.LoadPlan) error {
+	jumpbox := plan.Jumpbox
+	if jumpbox == "" {
+		jumpbox = "-"
+	}
+
+	runtime := plan.Runtime
+	if plan.Namespace != "" {
+		runtime += " (namespace " + plan.Namespace + ")"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Profile:\t%s\n", plan.Profile)
+	fmt.Fprintf(tw, "vCenter:\t%s\n", plan.VCenter)
+	fmt.Fprintf(tw, "Jumpbox:\t%s\n", jumpbox)
+	fmt.Fprintf(tw, "Control plane:\t%s\n", plan.ControlPlane)
+	fmt.Fprintf(tw, "VMs:\t%s\n", strings.Join(plan.VMs, ", "))
+	fmt.Fprintf(tw, "Runtime:\t%s\n", runtime)
+	fmt.Fprintf(tw, "Mode:\t%s\n", plan.Mode)
+	fmt.Fprintf(tw, "Strategy:\t%s\n", plan.Strategy)
+	fmt.Fprintf(tw, "Compression:\t%s\n", plan.Compress)
+	fmt.Fprintf(tw, "Staging directory:\t%s\n", plan.StagingDir)
+	fmt.Fprintf(tw, "Atomic:\t%t\n", plan.Atomic)
+	fmt.Fprintf(tw, "Pin:\t%t\n", plan.Pin)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	if err := printPreflight(w, plan.Preflight); err != nil {
+		return err
+	}
+
+	for _, c := range plan.Containers {
+		size := "not inspected"
+		if c.Size >= 0 {
+			size = fmt.Sprintf("%d bytes", c.Size)
+		}
+		fmt.Fprintf(w, "\n%s (%s)\n", c.Container, size)
+
+		for _, ref := range c.References {
+			fmt.Fprintf(w, "  image %s\n", ref)
+		}
+		for _, t := range c.Tags {
+			fmt.Fprintf(w, "  tag %s as %s\n", t.Source, t.Target)
+		}
+
+		for _, t := range c.Transfers {
+			switch {
+			case t.Err != nil:
+				fmt.Fprintf(w, "  %s: unable to check: %s\n", t.VM, t.Err)
+			case t.Action == pload.SkipAction:
+				fmt.Fprintf(w, "  %s: skip, images already present\n", t.VM)
+			case t.Compression != "":
+				fmt.Fprintf(w, "  %s: %s, %s compressed\n", t.VM, t.Method, t.Compression)
+			default:
+				fmt.Fprintf(w, "  %s: %s\n", t.VM, t.Method)
+			}
+
+			for _, c := range t.Commands {
+				fmt.Fprintf(w, "    $ %s\n", c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadCmdArgs holds the flags defined for the load command
+var loadCmdArgs struct {
+	Parallel      int
+	Mode          string
+	Force         bool
+	Atomic        bool
+	Tags          []string
+	As            string
+	Pin           bool
+	Strategy      string
+	Compress      string
+	FromProfile   string
+	StagingDir    string
+	PreflightOnly bool
+	DryRun        bool
+	Output        string
+	Runtime       string
+	Namespace     string
+}
+
 func init() {
+	loadCmd.Flags().IntVar(&loadCmdArgs.Parallel, "parallel", 3, "maximum number of VMs to load concurrently, 0 for all at once")
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.Mode, "mode", string(pload.StreamMode), "transfer mode: stream pipes the container into the runtime, staged copies it to the VM first, delta streams only missing layers")
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.Strategy, "strategy", string(pload.DirectStrategy), "upload strategy: direct uploads the container to every VM, relay uploads it to the first VM which forwards it to the others")
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.Compress, "compress", string(pload.NoCompression), fmt.Sprintf("compress containers in transit, one of %v; falls back to another compression, or none, on VMs missing the decompressor", pload.Compressions))
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.StagingDir, "staging-dir", "", "directory on the VMs containers are copied to before being imported; defaults to the profile's staging directory or /tmp")
+
+	loadCmd.Flags().BoolVar(&loadCmdArgs.PreflightOnly, "preflight-only", false, "check every VM is ready to be loaded and print the report without loading anything")
+
+	loadCmd.Flags().BoolVar(&loadCmdArgs.DryRun, "dry-run", false, "resolve the Supervisor and print what would be loaded where without copying or importing anything")
+	loadCmd.Flags().StringVarP(&loadCmdArgs.Output, "output", "o", "text", "dry-run output format: text or json")
+	loadCmd.MarkFlagsMutuallyExclusive("dry-run", "preflight-only")
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.FromProfile, "from-profile", "", "export the references given as arguments from the Supervisor of this profile")
+
+	loadCmd.Flags().BoolVar(&loadCmdArgs.Force, "force", false, "load even to VMs that already have every image in the container")
+
+	loadCmd.Flags().BoolVar(&loadCmdArgs.Atomic, "atomic", false, "restore the previous images on every VM if loading to any VM fails")
+
+	loadCmd.Flags().StringArrayVar(&loadCmdArgs.Tags, "tag", nil, "tag an imported image with another reference, as source=target; may be repeated")
+	loadCmd.Flags().StringVar(&loadCmdArgs.As, "as", "", "tag the only imported image with this reference")
+	loadCmd.MarkFlagsMutuallyExclusive("tag", "as")
+
+	loadCmd.Flags().BoolVar(&loadCmdArgs.Pin, "pin", false, "pin the imported images so the kubelet's image garbage collection can't remove them; not supported by the docker runtime")
+
+	loadCmd.Flags().StringVar(&loadCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime to load into, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+	loadCmd.Flags().StringVar(&loadCmdArgs.Namespace, "namespace", "", "containerd namespace to load into; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
 	root.Cmd().AddCommand(loadCmd)
 }
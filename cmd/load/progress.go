@@ -0,0 +1,188 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	pload "github.com/tvs/ultravisor/pkg/load"
+	"github.com/tvs/ultravisor/pkg/util/output"
+)
+
+// progressKey identifies a single transfer.
+type progressKey struct {
+	container string
+	vm        string
+}
+
+// progressBars draws a progress bar for every transfer, redrawing them in
+// place as progress is reported.
+type progressBars struct {
+	mu        sync.Mutex
+	w         io.Writer
+	order     []progressKey
+	transfers map[progressKey]pload.Progress
+	// drawn is the number of lines drawn by the last redraw.
+	drawn int
+	last  time.Time
+}
+
+func newProgressBars(w io.Writer) *progressBars {
+	return &progressBars{w: w, transfers: map[progressKey]pload.Progress{}}
+}
+
+// progressRedraw is the minimum time between redraws.
+const progressRedraw = 100 * time.Millisecond
+
+// update records the progress of a transfer and redraws the bars.
+func (b *progressBars) update(p pload.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	k := progressKey{container: p.Container, vm: p.VM}
+	if _, ok := b.transfers[k]; !ok {
+		b.order = append(b.order, k)
+	}
+	b.transfers[k] = p
+
+	// Always draw the final state of a transfer
+	if now := time.Now(); p.Done || now.Sub(b.last) >= progressRedraw {
+		b.last = now
+		b.redraw()
+	}
+}
+
+// clear erases the bars last drawn, leaving the cursor where they began.
+func (b *progressBars) clear() {
+	if b.drawn > 0 {
+		_, _ = fmt.Fprintf(b.w, "\x1b[%dA\r\x1b[J", b.drawn)
+		b.drawn = 0
+	}
+}
+
+// logWriter returns a writer for logs destined for w. Each write erases the
+// bars, writes the log to w and redraws the bars beneath it, so logs written
+// to the same terminal don't garble them.
+func (b *progressBars) logWriter(w io.Writer) io.Writer {
+	return &barsLogWriter{bars: b, w: w}
+}
+
+type barsLogWriter struct {
+	bars *progressBars
+	w    io.Writer
+}
+
+func (lw *barsLogWriter) Write(p []byte) (int, error) {
+	b := lw.bars
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+	n, err := lw.w.Write(p)
+	if len(b.order) > 0 {
+		b.redraw()
+	}
+
+	return n, err
+}
+
+func (b *progressBars) redraw() {
+	var sb strings.Builder
+	if b.drawn > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", b.drawn)
+	}
+
+	var width int
+	for _, k := range b.order {
+		width = max(width, len(filepath.Base(k.container))+len(k.vm)+1)
+	}
+
+	for _, k := range b.order {
+		name := filepath.Base(k.container) + " " + k.vm
+		fmt.Fprintf(&sb, "\r\x1b[K%-*s  %s\n", width, name, progressLine(b.transfers[k]))
+	}
+	b.drawn = len(b.order)
+
+	_, _ = io.WriteString(b.w, sb.String())
+}
+
+// progressBarWidth is the number of characters in a progress bar.
+const progressBarWidth = 30
+
+// progressLine describes a transfer as a bar followed by the bytes sent,
+// throughput and ETA. Transfers of unknown size have no bar.
+func progressLine(p pload.Progress) string {
+	rate := output.Size(int64(p.Rate())) + "/s"
+
+	if p.Total < 0 {
+		if p.Done {
+			return fmt.Sprintf("%s  %s  done in %s", output.Size(p.Sent), rate, p.Elapsed.Round(time.Second))
+		}
+		return fmt.Sprintf("%s  %s", output.Size(p.Sent), rate)
+	}
+
+	var fraction float64
+	if p.Total > 0 {
+		fraction = min(float64(p.Sent)/float64(p.Total), 1)
+	}
+
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "ETA -"
+	switch {
+	case p.Done:
+		eta = "done in " + p.Elapsed.Round(time.Second).String()
+	case p.ETA() >= 0:
+		eta = "ETA " + p.ETA().String()
+	}
+
+	return fmt.Sprintf("[%s] %3.0f%%  %s / %s  %s  %s", bar, fraction*100, output.Size(p.Sent), output.Size(p.Total), rate, eta)
+}
+
+// progressLogInterval is the minimum time between progress events of a single
+// transfer.
+const progressLogInterval = 5 * time.Second
+
+// progressLogger returns a progress func emitting periodic progress events of
+// every transfer through the logger.
+func progressLogger(l *zerolog.Logger) func(pload.Progress) {
+	var (
+		mu     sync.Mutex
+		logged = map[progressKey]time.Time{}
+	)
+
+	return func(p pload.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		k := progressKey{container: p.Container, vm: p.VM}
+		last, ok := logged[k]
+		if ok && !p.Done && time.Since(last) < progressLogInterval {
+			return
+		}
+		logged[k] = time.Now()
+
+		e := l.Info().
+			Str("file", p.Container).
+			Str("address", p.VM).
+			Int64("sent", p.Sent).
+			Int64("total", p.Total).
+			Float64("bytesPerSecond", p.Rate()).
+			Dur("elapsed", p.Elapsed)
+		if eta := p.ETA(); eta >= 0 && !p.Done {
+			e = e.Dur("eta", eta)
+		}
+
+		if p.Done {
+			e.Msg("transfer finished")
+			return
+		}
+		e.Msg("transfer progress")
+	}
+}
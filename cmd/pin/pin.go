@@ -0,0 +1,128 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	pload "github.com/tvs/ultravisor/pkg/load"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [reference|container...]",
+	Short: "pin images in the vSphere IaaS Control Plane",
+	Long: `pins images on each of the vSphere IaaS Control Plane's control plane VMs so the
+kubelet's image garbage collection can't remove them. Images may be given as
+references or as the container files that were loaded, in which case every
+reference in the container is pinned.`,
+	Example: "  pin localhost:5000/vmware/foo:1.2.3\n" +
+		"  pin container.tar",
+
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, args, false)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin [reference|container...]",
+	Short: "unpin images in the vSphere IaaS Control Plane",
+	Long: `unpins images on each of the vSphere IaaS Control Plane's control plane VMs,
+allowing the kubelet's image garbage collection to remove them again. Images
+may be given as references or as the container files that were loaded.`,
+	Example: "  unpin localhost:5000/vmware/foo:1.2.3\n" +
+		"  unpin container.tar",
+
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, args, true)
+	},
+}
+
+func run(cmd *cobra.Command, args []string, unpin bool) {
+	l := zerolog.Ctx(cmd.Context())
+
+	refs, err := pload.ResolveReferences(args)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to resolve references")
+		root.SetExitCode(1)
+		return
+	}
+
+	opts := pload.PinOptions{
+		Parallel:  pinCmdArgs.Parallel,
+		Unpin:     unpin,
+		Runtime:   pinCmdArgs.Runtime,
+		Namespace: pinCmdArgs.Namespace,
+	}
+
+	results, err := pload.Pin(cmd.Context(), refs, opts)
+
+	for _, r := range results {
+		if r.Err != nil {
+			l.Error().Err(r.Err).Str("address", r.VM).Dur("duration", r.Duration).Msg("failed to update pins")
+			continue
+		}
+
+		l.Info().Str("address", r.VM).Dur("duration", r.Duration).Bool("pinned", !unpin).Msg("updated pins")
+	}
+
+	if err := printPins(os.Stdout, results, !unpin); err != nil {
+		l.Error().Err(err).Msg("unable to print pin results")
+	}
+
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to update pins on vSphere IaaS Control Plane VMs")
+		root.SetExitCode(1)
+	}
+}
+
+// printPins writes a table of the pin results of every VM.
+func printPins(w io.Writer, results []pload.PinResult, pinned bool) error {
+	if !slices.ContainsFunc(results, func(r pload.PinResult) bool { return len(r.References)+len(r.Missing) > 0 }) {
+		return nil
+	}
+
+	status := "pinned"
+	if !pinned {
+		status = "unpinned"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VM\tREFERENCE\tSTATUS")
+
+	for _, r := range results {
+		for _, ref := range r.References {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.VM, ref, status)
+		}
+		for _, ref := range r.Missing {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.VM, ref, "missing")
+		}
+	}
+
+	return tw.Flush()
+}
+
+// pinCmdArgs holds the flags defined for the pin and unpin commands
+var pinCmdArgs struct {
+	Parallel  int
+	Runtime   string
+	Namespace string
+}
+
+func init() {
+	for _, c := range []*cobra.Command{pinCmd, unpinCmd} {
+		c.Flags().IntVar(&pinCmdArgs.Parallel, "parallel", 3, "maximum number of VMs to update concurrently, 0 for all at once")
+
+		c.Flags().StringVar(&pinCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime holding the images, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+		c.Flags().StringVar(&pinCmdArgs.Namespace, "namespace", "", "containerd namespace holding the images; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
+		root.Cmd().AddCommand(c)
+	}
+}
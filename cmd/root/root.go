@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
@@ -61,9 +63,16 @@ func Cmd() *cobra.Command {
 
 // Execute sets up logging, subcommands, and runs the root command. Errors are
 // emitted as logs, but otherwise swallowed and converted into error codes.
-// This is called by main.main() and ony needs to be called once.
+// This is called by main.main() and ony needs to be called once. The first
+// interrupt cancels the command's context so it may clean up; a second one
+// terminates immediately.
 func Execute() int {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default signal handling once cancelled
+	context.AfterFunc(ctx, stop)
+
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		l := zerolog.Ctx(rootCmd.Context())
 		l.Error().Err(err).Msg("An error occurred during execution")
@@ -80,6 +89,30 @@ func SetExitCode(c int) {
 	exitCode = c
 }
 
+// logOutput is where logs are written.
+var logOutput io.Writer = os.Stdout
+
+// LogOutput returns the writer logs are written to, formatted for the
+// terminal unless JSON log output was requested. Commands may wrap it to
+// coordinate their own terminal output with the logs.
+func LogOutput() io.Writer {
+	return logOutput
+}
+
+// JSON reports whether JSON log output was requested.
+func JSON() bool {
+	return rootCmdArgs.Json
+}
+
+// stderrLogs holds the conditions under which commands log to stderr.
+var stderrLogs = map[*cobra.Command]func() bool{}
+
+// LogToStderrWhen logs to stderr rather than stdout when cmd runs and cond is
+// true, for commands that may write data to stdout.
+func LogToStderrWhen(cmd *cobra.Command, cond func() bool) {
+	stderrLogs[cmd] = cond
+}
+
 // rootCmdArgs holds the flags defined for the root command
 var rootCmdArgs struct {
 	Profile string
@@ -94,14 +127,17 @@ func init() {
 }
 
 func configureLogger(cmd *cobra.Command) zerolog.Logger {
-	var w io.Writer
+	var w io.Writer = os.Stdout
+	if cond, ok := stderrLogs[cmd]; ok && cond() {
+		w = os.Stderr
+	}
 
 	if rootCmdArgs.Json {
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
-		w = os.Stdout
 	} else {
-		w = log.NewColorWriter(os.Stdout)
+		w = log.NewColorWriter(w)
 	}
+	logOutput = w
 
 	l := zerolog.New(w).With().Timestamp().Logger()
 	if rootCmdArgs.Verbose {
@@ -0,0 +1,189 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	"github.com/tvs/ultravisor/pkg/archive"
+	pload "github.com/tvs/ultravisor/pkg/load"
+)
+
+var saveCmd = &cobra.Command{
+	Use:   "save [reference...]",
+	Short: "save images from the vSphere IaaS Control Plane",
+	Long: `saves images from one of the vSphere IaaS Control Plane's control plane VMs to a
+local archive or OCI image layout directory. The images are exported by the
+VM's container runtime and streamed back through the jumpbox, if any. An output
+of - writes the archive to stdout, and logs to stderr.`,
+	Example: "  save localhost:5000/vmware/foo:1.2.3 -o foo.tar\n" +
+		"  save localhost:5000/vmware/foo:1.2.3 localhost:5000/vmware/bar:1.2.3 -o images.tar\n" +
+		"  save localhost:5000/vmware/foo:1.2.3 -o ./foo --format layout\n" +
+		"  save localhost:5000/vmware/foo:1.2.3 --vm 10.0.0.11 -o - | docker load",
+
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		l := zerolog.Ctx(cmd.Context())
+
+		refs := make([]string, len(args))
+		for i, arg := range args {
+			ref, err := archive.NormalizeReference(arg)
+			if err != nil {
+				l.Error().Err(err).Str("reference", arg).Msg("Invalid reference")
+				root.SetExitCode(1)
+				return
+			}
+			refs[i] = ref
+		}
+
+		out, format := saveCmdArgs.Output, saveCmdArgs.Format
+		if format != "archive" && format != "layout" {
+			l.Error().Str("format", format).Msg("Invalid format, must be archive or layout")
+			root.SetExitCode(1)
+			return
+		}
+
+		if format == "layout" && out == "-" {
+			l.Error().Msg("An OCI image layout can't be written to stdout")
+			root.SetExitCode(1)
+			return
+		}
+
+		opts := pload.SaveOptions{
+			VM:        saveCmdArgs.VM,
+			Runtime:   saveCmdArgs.Runtime,
+			Namespace: saveCmdArgs.Namespace,
+		}
+
+		vm, err := save(cmd, refs, out, format, opts)
+		if err != nil {
+			l.Error().Err(err).Str("address", vm).Msg("Unable to save images from vSphere IaaS Control Plane VM")
+			root.SetExitCode(1)
+			return
+		}
+
+		if out == "-" {
+			return
+		}
+
+		l.Info().Str("address", vm).Strs("references", refs).Str("output", out).Msg("saved images")
+
+		if format == "archive" {
+			a, err := archive.Inspect(out)
+			if err != nil {
+				l.Error().Err(err).Str("output", out).Msg("Unable to inspect saved archive")
+				root.SetExitCode(1)
+				return
+			}
+
+			for _, img := range a.Images {
+				l.Info().Strs("references", img.References).Str("digest", img.Digest).Msg("saved image")
+			}
+		}
+	},
+}
+
+// save streams the exported images to out in the requested format, removing
+// anything partially written if the export fails.
+func save(cmd *cobra.Command, refs []string, out, format string, opts pload.SaveOptions) (string, error) {
+	ctx := cmd.Context()
+
+	if out == "-" {
+		return pload.Save(ctx, refs, cmd.OutOrStdout(), opts)
+	}
+
+	if format == "layout" {
+		if _, err := os.Stat(out); err == nil {
+			return "", fmt.Errorf("%s already exists", out)
+		}
+
+		pr, pw := io.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			err := archive.ExtractLayout(pr, out)
+			pr.CloseWithError(err)
+			done <- err
+		}()
+
+		vm, err := pload.Save(ctx, refs, pw, opts)
+		pw.CloseWithError(err)
+		if eErr := <-done; err == nil {
+			err = eErr
+		}
+
+		if err != nil {
+			os.RemoveAll(out)
+		}
+		return vm, err
+	}
+
+	// Write alongside the output so a failed export doesn't clobber it
+	f, err := createTemp(filepath.Dir(out), "."+filepath.Base(out)+"-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create output file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	vm, err := pload.Save(ctx, refs, f, opts)
+	if cErr := f.Close(); err == nil && cErr != nil {
+		err = fmt.Errorf("unable to write output file: %w", cErr)
+	}
+	if err != nil {
+		return vm, err
+	}
+
+	if err := os.Rename(f.Name(), out); err != nil {
+		return vm, fmt.Errorf("unable to write output file: %w", err)
+	}
+
+	return vm, nil
+}
+
+// createTemp creates a new file in dir whose name begins with prefix. Unlike
+// os.CreateTemp, the file is created with the mode os.Create gives it, so the
+// output it is renamed to is as readable as the umask allows.
+func createTemp(dir, prefix string) (*os.File, error) {
+	for i := 0; i < 100; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+
+	return nil, fmt.Errorf("unable to find an unused name for a temporary file in %s", dir)
+}
+
+// saveCmdArgs holds the flags defined for the save command
+var saveCmdArgs struct {
+	Output    string
+	Format    string
+	VM        string
+	Runtime   string
+	Namespace string
+}
+
+func init() {
+	saveCmd.Flags().StringVarP(&saveCmdArgs.Output, "output", "o", "", "file or directory to save the images to, - for stdout")
+	_ = saveCmd.MarkFlagRequired("output")
+	saveCmd.Flags().StringVar(&saveCmdArgs.Format, "format", "archive", "output format: archive writes a tarball, layout writes an OCI image layout directory")
+	saveCmd.Flags().StringVar(&saveCmdArgs.VM, "vm", "", "address of the VM to save from; defaults to the first control plane VM")
+
+	saveCmd.Flags().StringVar(&saveCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime to save from, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+	saveCmd.Flags().StringVar(&saveCmdArgs.Namespace, "namespace", "", "containerd namespace to save from; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
+	// Logs would corrupt the archive written to stdout
+	root.LogToStderrWhen(saveCmd, func() bool { return saveCmdArgs.Output == "-" })
+
+	root.Cmd().AddCommand(saveCmd)
+}
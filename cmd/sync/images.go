@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	pload "github.com/tvs/ultravisor/pkg/load"
+	"github.com/tvs/ultravisor/pkg/util/output"
+)
+
+var syncImagesCmd = &cobra.Command{
+	Use:     "images [reference|container...]",
+	Aliases: []string{"image", "img"},
+	Short:   "copy images missing from some control plane VMs from another VM",
+	Long: `copies the images on a source control plane VM to every other control plane VM
+missing them. Images are piped from VM to VM over the management network rather
+than through this machine. The source defaults to the VM with the most images;
+images may be limited to references or the container files that were loaded.
+
+References that point at a different image on a VM than on the source are
+reported as conflicts and left alone, unless the source is named with --from or
+--force is given.`,
+	Example: "  sync images\n" +
+		"  sync images --from 10.0.0.11\n" +
+		"  sync images --force\n" +
+		"  sync images localhost:5000/vmware/foo:1.2.3\n" +
+		"  sync images container.tar",
+	Run: func(cmd *cobra.Command, args []string) {
+		l := zerolog.Ctx(cmd.Context())
+
+		var refs []string
+		if len(args) > 0 {
+			var err error
+			if refs, err = pload.ResolveReferences(args); err != nil {
+				l.Error().Err(err).Msg("Unable to resolve references")
+				root.SetExitCode(1)
+				return
+			}
+		}
+
+		opts := pload.SyncOptions{
+			Parallel:   syncImagesCmdArgs.Parallel,
+			Source:     syncImagesCmdArgs.From,
+			Force:      syncImagesCmdArgs.Force,
+			References: refs,
+			Runtime:    syncImagesCmdArgs.Runtime,
+			Namespace:  syncImagesCmdArgs.Namespace,
+		}
+
+		results, err := pload.Sync(cmd.Context(), opts)
+
+		var synced, current, failed, conflicted int
+		for _, r := range results {
+			for _, c := range r.Conflicts {
+				l.Warn().Str("address", r.VM).Str("source", r.Source).Str("reference", c.Reference).Str("expected", c.Expected).Str("actual", c.Actual).Msg("reference conflicts with source VM, use --from or --force to overwrite")
+			}
+			if len(r.Conflicts) > 0 {
+				conflicted++
+			}
+
+			switch {
+			case r.Err != nil:
+				failed++
+				l.Error().Err(r.Err).Str("address", r.VM).Str("source", r.Source).Dur("duration", r.Duration).Msg("failed to sync images")
+			case len(r.Verifications) == 0 && len(r.Conflicts) == 0:
+				current++
+				l.Info().Str("address", r.VM).Str("source", r.Source).Msg("images already in sync")
+			case len(r.Verifications) == 0:
+				// Only conflicts, already reported above
+			default:
+				synced++
+				l.Info().Str("address", r.VM).Str("source", r.Source).Int("images", len(r.Verifications)).Dur("duration", r.Duration).Msg("synced images")
+			}
+		}
+
+		if len(results) > 0 {
+			l.Info().Int("synced", synced).Int("current", current).Int("failed", failed).Int("conflicted", conflicted).Msg("sync summary")
+		}
+
+		if err := printVerifications(os.Stdout, results); err != nil {
+			l.Error().Err(err).Msg("unable to print verification results")
+		}
+
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to sync images between vSphere IaaS Control Plane VMs")
+			root.SetExitCode(1)
+		}
+	},
+}
+
+// printVerifications writes a table of the verification results of every VM.
+func printVerifications(w io.Writer, results []pload.SyncResult) error {
+	var rows []output.VerificationRow
+	for _, r := range results {
+		for _, v := range r.Verifications {
+			rows = append(rows, output.VerificationRow{
+				Origin:    r.Source,
+				VM:        r.VM,
+				Reference: v.Reference,
+				Expected:  v.Expected,
+				Actual:    v.Actual,
+				Status:    v.Status(),
+			})
+		}
+	}
+
+	return output.Verifications(w, "SOURCE", rows, false)
+}
+
+// syncImagesCmdArgs holds the flags defined for the sync images command
+var syncImagesCmdArgs struct {
+	Parallel  int
+	From      string
+	Force     bool
+	Runtime   string
+	Namespace string
+}
+
+func init() {
+	syncImagesCmd.Flags().IntVar(&syncImagesCmdArgs.Parallel, "parallel", 3, "maximum number of VMs to sync concurrently, 0 for all at once")
+	syncImagesCmd.Flags().StringVar(&syncImagesCmdArgs.From, "from", "", "address of the VM to copy images from; defaults to the VM with the most images")
+	syncImagesCmd.Flags().BoolVar(&syncImagesCmdArgs.Force, "force", false, "overwrite references that point at a different image than on the source VM")
+
+	syncImagesCmd.Flags().StringVar(&syncImagesCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime to sync, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+	syncImagesCmd.Flags().StringVar(&syncImagesCmdArgs.Namespace, "namespace", "", "containerd namespace to sync; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
+	syncCmd.AddCommand(syncImagesCmd)
+}
@@ -0,0 +1,17 @@
+package sync
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [resource]",
+	Short: "reconcile a resource across the vSphere IaaS Control Plane VMs",
+	Long:  `reconcile a named resource across the vSphere IaaS Control Plane's control plane VMs`,
+}
+
+func init() {
+	root.Cmd().AddCommand(syncCmd)
+}
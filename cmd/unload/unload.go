@@ -0,0 +1,110 @@
+package unload
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/tvs/ultravisor/cmd/root"
+	pload "github.com/tvs/ultravisor/pkg/load"
+)
+
+var unloadCmd = &cobra.Command{
+	Use:   "unload [reference|container...]",
+	Short: "remove images from the vSphere IaaS Control Plane",
+	Long: `removes images from each of the vSphere IaaS Control Plane's control plane VMs.
+Images may be given as references or as the container files that were loaded,
+in which case every reference in the container is removed. Images used by
+running containers are kept unless --force is given.`,
+	Example: "  unload localhost:5000/vmware/foo:1.2.3\n" +
+		"  unload container.tar\n" +
+		"  unload container.tar --force",
+
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		l := zerolog.Ctx(cmd.Context())
+
+		refs, err := pload.ResolveReferences(args)
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to resolve references")
+			root.SetExitCode(1)
+			return
+		}
+
+		opts := pload.UnloadOptions{
+			Parallel:  unloadCmdArgs.Parallel,
+			Force:     unloadCmdArgs.Force,
+			Runtime:   unloadCmdArgs.Runtime,
+			Namespace: unloadCmdArgs.Namespace,
+		}
+
+		results, err := pload.Unload(cmd.Context(), refs, opts)
+
+		var unloaded, failed int
+		for _, r := range results {
+			if r.Err != nil {
+				failed++
+				l.Error().Err(r.Err).Str("address", r.VM).Dur("duration", r.Duration).Msg("failed to unload images")
+				continue
+			}
+
+			unloaded++
+			l.Info().Str("address", r.VM).Dur("duration", r.Duration).Msg("unloaded images")
+		}
+
+		if len(results) > 0 {
+			l.Info().Int("references", len(refs)).Int("unloaded", unloaded).Int("failed", failed).Msg("unload summary")
+		}
+
+		if err := printRemovals(os.Stdout, results); err != nil {
+			l.Error().Err(err).Msg("unable to print removal results")
+		}
+
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to unload images from vSphere IaaS Control Plane VMs")
+			root.SetExitCode(1)
+		}
+	},
+}
+
+// printRemovals writes a table of the removal results of every VM.
+func printRemovals(w io.Writer, results []pload.UnloadResult) error {
+	if !slices.ContainsFunc(results, func(r pload.UnloadResult) bool { return len(r.Removals) > 0 }) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VM\tREFERENCE\tSTATUS")
+
+	for _, r := range results {
+		for _, rm := range r.Removals {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.VM, rm.Reference, rm.Status)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// unloadCmdArgs holds the flags defined for the unload command
+var unloadCmdArgs struct {
+	Parallel  int
+	Force     bool
+	Runtime   string
+	Namespace string
+}
+
+func init() {
+	unloadCmd.Flags().IntVar(&unloadCmdArgs.Parallel, "parallel", 3, "maximum number of VMs to unload concurrently, 0 for all at once")
+
+	unloadCmd.Flags().BoolVar(&unloadCmdArgs.Force, "force", false, "remove images even if they are used by running containers")
+
+	unloadCmd.Flags().StringVar(&unloadCmdArgs.Runtime, "runtime", "", fmt.Sprintf("container runtime to remove from, one of %v; defaults to the profile's runtime or ctr", pload.Runtimes))
+	unloadCmd.Flags().StringVar(&unloadCmdArgs.Namespace, "namespace", "", "containerd namespace to remove from; defaults to the profile's namespace or "+pload.DefaultNamespace)
+
+	root.Cmd().AddCommand(unloadCmd)
+}
@@ -3,20 +3,21 @@ module github.com/tvs/ultravisor
 go 1.21.9
 
 require (
+	github.com/klauspost/compress v1.17.11
+	github.com/mattn/go-isatty v0.0.19
 	github.com/rs/zerolog v1.32.0
 	github.com/spf13/cobra v1.8.0
 	github.com/tvs/sshit v0.0.0-20240604222915-74e6ffbcfada
 	github.com/vmware/govmomi v0.37.2
 	golang.org/x/crypto v0.24.0
+	golang.org/x/sync v0.7.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/rogpeppe/go-internal v1.6.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,353 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Format is the layout of an image archive.
+type Format string
+
+const (
+	// DockerFormat is the layout produced by `docker save`, described by a
+	// manifest.json at the root of the archive.
+	DockerFormat Format = "docker-archive"
+	// OCIFormat is an OCI image layout, described by an index.json at the root
+	// of the archive.
+	OCIFormat Format = "oci-archive"
+)
+
+// ErrInvalid is returned when a file is not a usable image archive.
+var ErrInvalid = errors.New("invalid image archive")
+
+// maxInlineSize is the largest archive entry that is kept in memory while
+// inspecting. Manifests, indexes and configs are all well below this.
+const maxInlineSize = 4 << 20
+
+// Archive describes the images contained in an image archive.
+type Archive struct {
+	// Path is the location of the archive on the local machine, if any.
+	Path string `json:"path,omitempty"`
+	// Format is the layout of the archive.
+	Format Format `json:"format"`
+	// Size is the size of the archive in bytes.
+	Size int64 `json:"size"`
+	// Digest is the sha256 digest of the archive.
+	Digest string `json:"digest"`
+	// Images are the images contained in the archive.
+	Images []Image `json:"images"`
+
+	entries map[string]*entry
+}
+
+// Image describes a single image within an archive.
+type Image struct {
+	// References are the normalized names the image is imported as.
+	References []string `json:"references,omitempty"`
+	// Digest is the digest of the image's manifest or index as it is stored by
+	// containerd once imported.
+	Digest string `json:"digest"`
+	// MediaType is the media type of the image's manifest or index.
+	MediaType string `json:"mediaType"`
+	// Size is the total size of the manifests, configs and layers of the image
+	// contained in the archive.
+	Size int64 `json:"size"`
+	// Config is the digest of the image config. It is empty for indexes.
+	Config string `json:"config,omitempty"`
+	// Layers are the layers of the image contained in the archive.
+	Layers []Descriptor `json:"layers,omitempty"`
+
+	// manifest holds the manifest generated for docker-archive images, which
+	// is not itself part of the archive.
+	manifest []byte
+}
+
+// Descriptor describes content within an image, following the OCI content
+// descriptor.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform describes the platform an image manifest targets.
+type Platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"os.version,omitempty"`
+	OSFeatures   []string `json:"os.features,omitempty"`
+	Variant      string   `json:"variant,omitempty"`
+}
+
+// entry is a file within the archive.
+type entry struct {
+	name   string
+	size   int64
+	digest string
+	magic  []byte
+	data   []byte
+	link   string
+}
+
+// References returns the references of every image in the archive.
+func (a *Archive) References() []string {
+	var refs []string
+	for _, img := range a.Images {
+		refs = append(refs, img.References...)
+	}
+
+	return refs
+}
+
+// Inspect reads the image archive at p and describes the images within it. An
+// error wrapping ErrInvalid is returned if the file is compressed, corrupt, or
+// is not a docker-archive or OCI layout tarball.
+func Inspect(p string) (*Archive, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive: %w", err)
+	}
+	defer f.Close()
+
+	a, err := InspectReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Path = p
+	return a, nil
+}
+
+// InspectReader reads an image archive from r and describes the images within
+// it. The reader is consumed in its entirety.
+func InspectReader(r io.Reader) (*Archive, error) {
+	h := sha256.New()
+	cr := &countingReader{r: io.TeeReader(r, h)}
+	br := bufio.NewReader(cr)
+
+	if err := checkCompression(br); err != nil {
+		return nil, err
+	}
+
+	entries, err := readEntries(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drain any trailing padding so the size reflects the whole archive
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, fmt.Errorf("%w: unable to read archive: %v", ErrInvalid, err)
+	}
+
+	a := &Archive{
+		Size:    cr.n,
+		Digest:  "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		entries: entries,
+	}
+
+	// docker save writes both an OCI layout and manifest.json since Docker 25.
+	// containerd imports the index in that case, so it must be preferred for
+	// the digests to match.
+	switch {
+	case entries["oci-layout"] != nil || entries["index.json"] != nil:
+		a.Format = OCIFormat
+		err = a.readOCIIndex()
+	case entries["manifest.json"] != nil:
+		a.Format = DockerFormat
+		err = a.readDockerManifest()
+	default:
+		return nil, fmt.Errorf("%w: neither manifest.json nor index.json found; not a docker-archive or OCI layout", ErrInvalid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.Images) == 0 {
+		return nil, fmt.Errorf("%w: archive does not contain any images", ErrInvalid)
+	}
+
+	return a, nil
+}
+
+// checkCompression rejects archives that are compressed as a whole; the
+// container runtime only imports plain tarballs.
+func checkCompression(br *bufio.Reader) error {
+	magic, err := br.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: unable to read archive: %v", ErrInvalid, err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return fmt.Errorf("%w: archive is gzip compressed; decompress it before loading", ErrInvalid)
+	case bytes.HasPrefix(magic, zstdMagic):
+		return fmt.Errorf("%w: archive is zstd compressed; decompress it before loading", ErrInvalid)
+	}
+
+	return nil
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// readEntries walks the tarball, hashing every file and keeping small files in
+// memory. Links are resolved once the whole tarball has been read.
+func readEntries(r io.Reader) (map[string]*entry, error) {
+	entries := map[string]*entry{}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: archive is corrupt or not a tarball: %v", ErrInvalid, err)
+		}
+
+		name := cleanName(hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			e, err := readEntry(name, hdr.Size, tr)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to read %s: %v", ErrInvalid, name, err)
+			}
+			entries[name] = e
+		case tar.TypeSymlink:
+			entries[name] = &entry{name: name, link: cleanName(path.Join(path.Dir(name), hdr.Linkname))}
+		case tar.TypeLink:
+			entries[name] = &entry{name: name, link: cleanName(hdr.Linkname)}
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: archive is empty or not a tarball", ErrInvalid)
+	}
+
+	for name, e := range entries {
+		if e.link == "" {
+			continue
+		}
+
+		target, err := resolveLink(entries, e)
+		if err != nil {
+			return nil, err
+		}
+		entries[name] = &entry{name: name, size: target.size, digest: target.digest, magic: target.magic, data: target.data, link: target.name}
+	}
+
+	return entries, nil
+}
+
+func readEntry(name string, size int64, r io.Reader) (*entry, error) {
+	h := sha256.New()
+	var data bytes.Buffer
+
+	w := io.Writer(h)
+	if size <= maxInlineSize {
+		w = io.MultiWriter(h, &data)
+	}
+
+	// Keep the leading bytes so layer compression can be detected
+	var magic bytes.Buffer
+	n, err := io.Copy(w, io.TeeReader(io.LimitReader(r, int64(len(zstdMagic))), &magic))
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := io.Copy(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n+m != size {
+		return nil, fmt.Errorf("expected %d bytes, read %d", size, n+m)
+	}
+
+	e := &entry{
+		name:   name,
+		size:   size,
+		digest: "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		magic:  magic.Bytes(),
+	}
+	if size <= maxInlineSize {
+		e.data = data.Bytes()
+	}
+
+	return e, nil
+}
+
+func resolveLink(entries map[string]*entry, e *entry) (*entry, error) {
+	seen := map[string]bool{}
+	for e.link != "" {
+		if seen[e.name] {
+			return nil, fmt.Errorf("%w: link cycle at %s", ErrInvalid, e.name)
+		}
+		seen[e.name] = true
+
+		target, ok := entries[e.link]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s links to missing file %s", ErrInvalid, e.name, e.link)
+		}
+		e = target
+	}
+
+	return e, nil
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// file returns the contents of a small file within the archive.
+func (a *Archive) file(name string) ([]byte, error) {
+	e, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s not found in archive", ErrInvalid, name)
+	}
+
+	if e.data == nil {
+		return nil, fmt.Errorf("%w: %s is too large (%d bytes)", ErrInvalid, name, e.size)
+	}
+
+	return e.data, nil
+}
+
+// layerMediaType determines the media type containerd assigns to a layer,
+// based on its compression.
+func layerMediaType(e *entry) string {
+	switch {
+	case bytes.HasPrefix(e.magic, gzipMagic):
+		return MediaTypeDockerLayerGzip
+	case bytes.HasPrefix(e.magic, zstdMagic):
+		return MediaTypeOCILayerZstd
+	default:
+		return MediaTypeDockerLayer
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,236 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// Fixture contents shared by every archive layout.
+const (
+	testLayer  = "layer-one\n"
+	testConfig = `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["sha256:x"]}}`
+)
+
+func hexDigest(b string) string {
+	h := sha256.Sum256([]byte(b))
+	return hex.EncodeToString(h[:])
+}
+
+// testManifest is the OCI manifest of the fixture image.
+var testManifest = fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+	`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:%s","size":%d},`+
+	`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:%s","size":%d}]}`,
+	hexDigest(testConfig), len(testConfig), hexDigest(testLayer), len(testLayer))
+
+// testIndex is the index.json of the fixture layouts, naming the manifest as
+// docker save and ctr export do.
+var testIndex = fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json",`+
+	`"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:%s","size":%d,`+
+	`"annotations":{"io.containerd.image.name":"docker.io/library/foo:dev","org.opencontainers.image.ref.name":"dev"}}]}`,
+	hexDigest(testManifest), len(testManifest))
+
+type file struct {
+	name, data string
+}
+
+func tarball(t *testing.T, files ...file) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Size: int64(len(f.data)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// legacyDockerSave is the layout docker save wrote before Docker 25.
+func legacyDockerSave(t *testing.T) []byte {
+	cfg, layer := hexDigest(testConfig), hexDigest(testLayer)
+	return tarball(t,
+		file{"manifest.json", fmt.Sprintf(`[{"Config":"%s.json","RepoTags":["foo:dev"],"Layers":["%s/layer.tar"]}]`, cfg, layer)},
+		file{cfg + ".json", testConfig},
+		file{layer + "/layer.tar", testLayer},
+	)
+}
+
+// ctrExport is the layout written by ctr export.
+func ctrExport(t *testing.T) []byte {
+	return tarball(t,
+		file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		file{"index.json", testIndex},
+		file{"blobs/sha256/" + hexDigest(testManifest), testManifest},
+		file{"blobs/sha256/" + hexDigest(testConfig), testConfig},
+		file{"blobs/sha256/" + hexDigest(testLayer), testLayer},
+	)
+}
+
+// dockerSave25 is the layout docker save writes since Docker 25: an OCI
+// layout alongside the legacy manifest.json.
+func dockerSave25(t *testing.T) []byte {
+	return tarball(t,
+		file{"blobs/sha256/" + hexDigest(testConfig), testConfig},
+		file{"blobs/sha256/" + hexDigest(testLayer), testLayer},
+		file{"blobs/sha256/" + hexDigest(testManifest), testManifest},
+		file{"index.json", testIndex},
+		file{"manifest.json", fmt.Sprintf(`[{"Config":"blobs/sha256/%s","RepoTags":["foo:dev"],"Layers":["blobs/sha256/%s"]}]`, hexDigest(testConfig), hexDigest(testLayer))},
+		file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+	)
+}
+
+func TestInspectReaderDigests(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive func(*testing.T) []byte
+		format  Format
+		digest  string
+	}{
+		{
+			name:    "legacy docker save",
+			archive: legacyDockerSave,
+			format:  DockerFormat,
+			// The manifest containerd generates from manifest.json
+			digest: "sha256:96f85c7ab49d95d4da68b3d33dcc7039040f02d4c7c1e3e706553eea38be4576",
+		},
+		{
+			name:    "docker 25 save",
+			archive: dockerSave25,
+			format:  OCIFormat,
+			digest:  "sha256:18ce9056b8caf8dfdb85a7b1f7cf0d557a68c4829d06d04f51f0334f26841024",
+		},
+		{
+			name:    "oci layout",
+			archive: ctrExport,
+			format:  OCIFormat,
+			digest:  "sha256:18ce9056b8caf8dfdb85a7b1f7cf0d557a68c4829d06d04f51f0334f26841024",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := InspectReader(bytes.NewReader(tt.archive(t)))
+			if err != nil {
+				t.Fatalf("InspectReader() error = %v", err)
+			}
+
+			if a.Format != tt.format {
+				t.Errorf("Format = %s, want %s", a.Format, tt.format)
+			}
+
+			if len(a.Images) != 1 {
+				t.Fatalf("got %d images, want 1", len(a.Images))
+			}
+
+			img := a.Images[0]
+			if img.Digest != tt.digest {
+				t.Errorf("Digest = %s, want %s", img.Digest, tt.digest)
+			}
+
+			if want := []string{"docker.io/library/foo:dev"}; !slices.Equal(img.References, want) {
+				t.Errorf("References = %v, want %v", img.References, want)
+			}
+
+			if want := "sha256:" + hexDigest(testConfig); img.Config != want {
+				t.Errorf("Config = %s, want %s", img.Config, want)
+			}
+		})
+	}
+}
+
+func TestInspectReaderNamesFromDockerManifest(t *testing.T) {
+	unnamed := fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:%s","size":%d}]}`,
+		hexDigest(testManifest), len(testManifest))
+
+	b := tarball(t,
+		file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		file{"index.json", unnamed},
+		file{"manifest.json", fmt.Sprintf(`[{"Config":"blobs/sha256/%s","RepoTags":["foo:dev"],"Layers":["blobs/sha256/%s"]}]`, hexDigest(testConfig), hexDigest(testLayer))},
+		file{"blobs/sha256/" + hexDigest(testManifest), testManifest},
+		file{"blobs/sha256/" + hexDigest(testConfig), testConfig},
+		file{"blobs/sha256/" + hexDigest(testLayer), testLayer},
+	)
+
+	a, err := InspectReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("InspectReader() error = %v", err)
+	}
+
+	if want := []string{"docker.io/library/foo:dev"}; !slices.Equal(a.References(), want) {
+		t.Errorf("References() = %v, want %v", a.References(), want)
+	}
+}
+
+func TestInspectReaderInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{name: "empty", archive: nil},
+		{name: "gzip", archive: []byte{0x1f, 0x8b, 0x08, 0x00}},
+		{name: "zstd", archive: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		{name: "no manifest", archive: tarball(t, file{"hello.txt", "hello"})},
+		{name: "corrupt blob", archive: tarball(t,
+			file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+			file{"index.json", testIndex},
+			file{"blobs/sha256/" + hexDigest(testManifest), testManifest + " "},
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := InspectReader(bytes.NewReader(tt.archive)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("InspectReader() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "foo", want: "docker.io/library/foo:latest"},
+		{ref: "foo:dev", want: "docker.io/library/foo:dev"},
+		{ref: "tvs/foo:dev", want: "docker.io/tvs/foo:dev"},
+		{ref: "docker.io/library/foo:dev", want: "docker.io/library/foo:dev"},
+		{ref: "localhost/foo", want: "localhost/foo:latest"},
+		{ref: "localhost:5000/vmware/foo:1.2.3", want: "localhost:5000/vmware/foo:1.2.3"},
+		{ref: "registry.example.com/a/b/c:v1", want: "registry.example.com/a/b/c:v1"},
+		{ref: "foo@sha256:abc", want: "docker.io/library/foo@sha256:abc"},
+		{ref: "foo:dev@sha256:abc", want: "docker.io/library/foo:dev@sha256:abc"},
+		{ref: "", wantErr: true},
+		{ref: "foo bar", wantErr: true},
+		{ref: "docker.io/Foo", wantErr: true},
+		{ref: "localhost:5000/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := NormalizeReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeReference(%q) error = %v, wantErr %t", tt.ref, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("NormalizeReference(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
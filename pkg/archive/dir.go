@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteLayoutDir writes the OCI image layout in dir to w as a tarball. When
+// tag is set only the images whose ref name annotation matches it are
+// included. Images without an io.containerd.image.name annotation are named
+// from their ref name, or from the directory name and tag if the ref name is a
+// bare tag, so they can be found once imported.
+func WriteLayoutDir(w io.Writer, dir, tag string) error {
+	fsys := os.DirFS(dir)
+
+	layout, err := fs.ReadFile(fsys, "oci-layout")
+	if err != nil {
+		return fmt.Errorf("%w: %s is not an OCI layout: %v", ErrInvalid, dir, err)
+	}
+
+	b, err := fs.ReadFile(fsys, "index.json")
+	if err != nil {
+		return fmt.Errorf("%w: %s is not an OCI layout: %v", ErrInvalid, dir, err)
+	}
+
+	var idx ociIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return fmt.Errorf("%w: unable to parse index.json: %v", ErrInvalid, err)
+	}
+
+	repo := strings.ToLower(filepath.Base(filepath.Clean(dir)))
+
+	var selected []Descriptor
+	for _, desc := range idx.Manifests {
+		refName := desc.Annotations[AnnotationRefName]
+		if tag != "" && refName != tag && desc.Annotations[AnnotationImageName] != tag {
+			continue
+		}
+
+		if imageName(desc.Annotations) == "" && refName != "" {
+			name, err := NormalizeReference(repo + ":" + refName)
+			if err != nil {
+				return fmt.Errorf("unable to name image tagged %q: %w", refName, err)
+			}
+
+			annotations := map[string]string{AnnotationImageName: name}
+			for k, v := range desc.Annotations {
+				annotations[k] = v
+			}
+			desc.Annotations = annotations
+		}
+
+		selected = append(selected, desc)
+	}
+
+	if len(selected) == 0 {
+		return fmt.Errorf("no image tagged %q in OCI layout %s", tag, dir)
+	}
+
+	blobs := map[string]bool{}
+	for _, desc := range selected {
+		if err := layoutDirBlobs(fsys, desc, true, blobs); err != nil {
+			return err
+		}
+	}
+
+	idx.Manifests = selected
+	index, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("unable to generate index: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+
+	if err := writeFile(tw, "oci-layout", layout); err != nil {
+		return err
+	}
+
+	if err := writeFile(tw, "index.json", index); err != nil {
+		return err
+	}
+
+	for d := range blobs {
+		if err := copyBlob(tw, fsys, blobName(d)); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// layoutDirBlobs adds the digests of desc and everything it references within
+// the layout to blobs.
+func layoutDirBlobs(fsys fs.FS, desc Descriptor, required bool, blobs map[string]bool) error {
+	name := blobName(desc.Digest)
+	if _, err := fs.Stat(fsys, name); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("%w: blob %s: %v", ErrInvalid, desc.Digest, err)
+	}
+	blobs[desc.Digest] = true
+
+	switch desc.MediaType {
+	case MediaTypeOCIIndex, MediaTypeDockerManifestList:
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		var idx ociIndex
+		if err := json.Unmarshal(b, &idx); err != nil {
+			return fmt.Errorf("%w: unable to parse index %s: %v", ErrInvalid, desc.Digest, err)
+		}
+
+		for _, child := range idx.Manifests {
+			if err := layoutDirBlobs(fsys, child, false, blobs); err != nil {
+				return err
+			}
+		}
+	case MediaTypeOCIManifest, MediaTypeDockerManifest:
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		var mfst ociManifest
+		if err := json.Unmarshal(b, &mfst); err != nil {
+			return fmt.Errorf("%w: unable to parse manifest %s: %v", ErrInvalid, desc.Digest, err)
+		}
+
+		for _, d := range append([]Descriptor{mfst.Config}, mfst.Layers...) {
+			if err := layoutDirBlobs(fsys, d, true, blobs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func copyBlob(tw *tar.Writer, fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     stat.Size(),
+		Mode:     0644,
+	}); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Media types used by docker-archive images once converted by containerd.
+const (
+	MediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerConfig    = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer     = "application/vnd.docker.image.rootfs.diff.tar"
+	MediaTypeDockerLayerGzip = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// dockerManifest is an entry in a docker-archive's manifest.json.
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// readDockerManifest describes the images listed in manifest.json. As the
+// archive carries no registry manifest, one is generated the same way
+// containerd does on import so the digest matches what the runtime reports.
+func (a *Archive) readDockerManifest() error {
+	b, err := a.file("manifest.json")
+	if err != nil {
+		return err
+	}
+
+	var mfsts []dockerManifest
+	if err := json.Unmarshal(b, &mfsts); err != nil {
+		return fmt.Errorf("%w: unable to parse manifest.json: %v", ErrInvalid, err)
+	}
+
+	for _, mfst := range mfsts {
+		img, err := a.dockerImage(mfst)
+		if err != nil {
+			return err
+		}
+
+		a.Images = append(a.Images, img)
+	}
+
+	return nil
+}
+
+func (a *Archive) dockerImage(mfst dockerManifest) (Image, error) {
+	cfg, ok := a.entries[cleanName(mfst.Config)]
+	if !ok {
+		return Image{}, fmt.Errorf("%w: config %s not found in archive", ErrInvalid, mfst.Config)
+	}
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeDockerManifest,
+		Config: Descriptor{
+			MediaType: MediaTypeDockerConfig,
+			Digest:    cfg.digest,
+			Size:      cfg.size,
+		},
+	}
+
+	size := cfg.size
+	for _, name := range mfst.Layers {
+		layer, ok := a.entries[cleanName(name)]
+		if !ok {
+			return Image{}, fmt.Errorf("%w: layer %s not found in archive", ErrInvalid, name)
+		}
+
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType: layerMediaType(layer),
+			Digest:    layer.digest,
+			Size:      layer.size,
+		})
+		size += layer.size
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return Image{}, fmt.Errorf("unable to generate manifest: %w", err)
+	}
+	h := sha256.Sum256(b)
+
+	var refs []string
+	for _, tag := range mfst.RepoTags {
+		ref, err := NormalizeReference(tag)
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		refs = append(refs, ref)
+	}
+
+	return Image{
+		References: refs,
+		Digest:     "sha256:" + hex.EncodeToString(h[:]),
+		MediaType:  MediaTypeDockerManifest,
+		Size:       size + int64(len(b)),
+		Config:     cfg.digest,
+		Layers:     manifest.Layers,
+		manifest:   b,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractLayout unpacks an OCI archive read from r into dir as an OCI image
+// layout. Only regular files and directories are extracted; entries that would
+// land outside dir are rejected.
+func ExtractLayout(r io.Reader, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create layout directory: %w", err)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: unable to read archive: %v", ErrInvalid, err)
+		}
+
+		name := filepath.FromSlash(strings.TrimPrefix(hdr.Name, "./"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: entry %q is outside the layout", ErrInvalid, hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, required := range []string{"oci-layout", "index.json"} {
+		if _, err := os.Stat(filepath.Join(dir, required)); err != nil {
+			return fmt.Errorf("%w: %s not found; not an OCI archive", ErrInvalid, required)
+		}
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %w", target, err)
+	}
+
+	return f.Close()
+}
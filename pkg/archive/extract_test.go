@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExtractLayout(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "layout")
+	if err := ExtractLayout(bytes.NewReader(ctrExport(t)), dir); err != nil {
+		t.Fatalf("ExtractLayout() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": testIndex,
+		"blobs/sha256/" + hexDigest(testManifest): testManifest,
+		"blobs/sha256/" + hexDigest(testConfig):   testConfig,
+		"blobs/sha256/" + hexDigest(testLayer):    testLayer,
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("unable to read %s: %v", name, err)
+			continue
+		}
+
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+
+	// The layout is a directory WriteLayoutDir can load again
+	var buf bytes.Buffer
+	if err := WriteLayoutDir(&buf, dir, "dev"); err != nil {
+		t.Fatalf("WriteLayoutDir() error = %v", err)
+	}
+
+	a, err := InspectReader(&buf)
+	if err != nil {
+		t.Fatalf("InspectReader() error = %v", err)
+	}
+
+	if want := []string{"docker.io/library/foo:dev"}; !slices.Equal(a.References(), want) {
+		t.Errorf("References() = %v, want %v", a.References(), want)
+	}
+}
+
+func TestExtractLayoutInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{name: "not a tarball", archive: []byte("not a tarball at all, but long enough to be read as a header")},
+		{name: "docker-archive", archive: legacyDockerSave(t)},
+		{name: "outside layout", archive: tarball(t,
+			file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+			file{"index.json", testIndex},
+			file{"../escaped", "escaped"},
+		)},
+		{name: "absolute", archive: tarball(t,
+			file{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+			file{"/etc/escaped", "escaped"},
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "layout")
+
+			if err := ExtractLayout(bytes.NewReader(tt.archive), dir); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("ExtractLayout() error = %v, want ErrInvalid", err)
+			}
+
+			if _, err := os.Stat(filepath.Join(parent, "escaped")); err == nil {
+				t.Error("ExtractLayout() wrote a file outside the layout")
+			}
+		})
+	}
+}
+
+func TestExtractLayoutSkipsLinks(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Typeflag: tar.TypeSymlink, Name: "blobs/sha256/link", Linkname: "/etc/passwd"},
+		{Typeflag: tar.TypeDir, Name: "blobs/sha256/", Mode: 0755},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []file{{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`}, {"index.json", testIndex}} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Size: int64(len(f.data)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "layout")
+	if err := ExtractLayout(&buf, dir); err != nil {
+		t.Fatalf("ExtractLayout() error = %v", err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "blobs", "sha256", "link")); err == nil {
+		t.Error("ExtractLayout() extracted a symlink")
+	}
+}
@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// WriteLayout writes the archive to w as an OCI image layout tarball, omitting
+// any layer for which skip returns true. Manifests, indexes and configs are
+// always written so the images can be recreated from layers that already
+// exist where the layout is imported. The contents of the original archive are
+// read from the file at the archive's path.
+func (a *Archive) WriteLayout(w io.Writer, skip func(digest string) bool) error {
+	if a.Path == "" {
+		return fmt.Errorf("archive has no path to read from")
+	}
+
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open archive: %w", err)
+	}
+	defer f.Close()
+
+	return a.writeLayout(w, f, skip)
+}
+
+func (a *Archive) writeLayout(w io.Writer, r io.Reader, skip func(digest string) bool) error {
+	tw := tar.NewWriter(w)
+
+	if err := writeFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+
+	index, err := a.layoutIndex()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(tw, "index.json", index); err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	for _, img := range a.Images {
+		if img.manifest == nil || written[img.Digest] {
+			continue
+		}
+
+		if err := writeFile(tw, blobName(img.Digest), img.manifest); err != nil {
+			return err
+		}
+		written[img.Digest] = true
+	}
+
+	wanted := a.layoutBlobs(skip)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read archive: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		e, ok := a.entries[cleanName(hdr.Name)]
+		if !ok || !wanted[e.digest] || written[e.digest] {
+			continue
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     blobName(e.digest),
+			Size:     e.size,
+			Mode:     0644,
+		}); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("unable to copy %s: %w", hdr.Name, err)
+		}
+		written[e.digest] = true
+	}
+
+	for d := range wanted {
+		if !written[d] {
+			return fmt.Errorf("%w: blob %s not found in archive", ErrInvalid, d)
+		}
+	}
+
+	return tw.Close()
+}
+
+// layoutIndex returns the index.json for the layout. OCI archives keep their
+// own index, while docker-archives get an index naming each generated
+// manifest with its references.
+func (a *Archive) layoutIndex() ([]byte, error) {
+	if a.Format == OCIFormat {
+		return a.file("index.json")
+	}
+
+	idx := ociIndex{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIIndex,
+		Manifests:     []Descriptor{},
+	}
+
+	for _, img := range a.Images {
+		desc := Descriptor{
+			MediaType: img.MediaType,
+			Digest:    img.Digest,
+			Size:      int64(len(img.manifest)),
+		}
+
+		if len(img.References) == 0 {
+			idx.Manifests = append(idx.Manifests, desc)
+			continue
+		}
+
+		for _, ref := range img.References {
+			d := desc
+			d.Annotations = map[string]string{AnnotationImageName: ref}
+			idx.Manifests = append(idx.Manifests, d)
+		}
+	}
+
+	return json.Marshal(idx)
+}
+
+// layoutBlobs returns the digests of the blobs to copy into the layout: every
+// non-layer blob, plus the layers that aren't skipped.
+func (a *Archive) layoutBlobs(skip func(digest string) bool) map[string]bool {
+	layers := map[string]bool{}
+	for _, img := range a.Images {
+		for _, layer := range img.Layers {
+			layers[layer.Digest] = true
+		}
+	}
+
+	wanted := map[string]bool{}
+	for d := range layers {
+		if !skip(d) {
+			wanted[d] = true
+		}
+	}
+
+	switch a.Format {
+	case DockerFormat:
+		for _, img := range a.Images {
+			wanted[img.Config] = true
+		}
+	case OCIFormat:
+		for name, e := range a.entries {
+			if strings.HasPrefix(name, "blobs/") && !layers[e.digest] {
+				wanted[e.digest] = true
+			}
+		}
+	}
+
+	return wanted
+}
+
+func writeFile(tw *tar.Writer, name string, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(b)),
+		Mode:     0644,
+	}); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(b)
+	return err
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// tarNames returns the names of the files in the tarball b.
+func tarNames(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+// layoutBlob returns the hex digest of a blob in a layout.
+func layoutBlob(name string) (string, bool) {
+	dir, blob := filepath.Split(name)
+	return blob, dir == "blobs/sha256/"
+}
+
+func TestWriteLayout(t *testing.T) {
+	layer := "sha256:" + hexDigest(testLayer)
+
+	tests := []struct {
+		name    string
+		archive func(*testing.T) []byte
+		skip    func(string) bool
+		digest  string
+		blobs   []string
+	}{
+		{
+			name:    "legacy docker save",
+			archive: legacyDockerSave,
+			skip:    func(string) bool { return false },
+			digest:  "sha256:96f85c7ab49d95d4da68b3d33dcc7039040f02d4c7c1e3e706553eea38be4576",
+			blobs:   []string{"96f85c7ab49d95d4da68b3d33dcc7039040f02d4c7c1e3e706553eea38be4576", hexDigest(testConfig), hexDigest(testLayer)},
+		},
+		{
+			name:    "legacy docker save skipping layers",
+			archive: legacyDockerSave,
+			skip:    func(d string) bool { return d == layer },
+			blobs:   []string{"96f85c7ab49d95d4da68b3d33dcc7039040f02d4c7c1e3e706553eea38be4576", hexDigest(testConfig)},
+		},
+		{
+			name:    "oci layout",
+			archive: ctrExport,
+			skip:    func(string) bool { return false },
+			digest:  "sha256:18ce9056b8caf8dfdb85a7b1f7cf0d557a68c4829d06d04f51f0334f26841024",
+			blobs:   []string{hexDigest(testManifest), hexDigest(testConfig), hexDigest(testLayer)},
+		},
+		{
+			name:    "oci layout skipping layers",
+			archive: ctrExport,
+			skip:    func(d string) bool { return d == layer },
+			blobs:   []string{hexDigest(testManifest), hexDigest(testConfig)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "foo.tar")
+			if err := os.WriteFile(p, tt.archive(t), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := Inspect(p)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := a.WriteLayout(&buf, tt.skip); err != nil {
+				t.Fatalf("WriteLayout() error = %v", err)
+			}
+
+			var blobs []string
+			for _, name := range tarNames(t, buf.Bytes()) {
+				if name == "oci-layout" || name == "index.json" {
+					continue
+				}
+
+				blob, ok := layoutBlob(name)
+				if !ok {
+					t.Errorf("unexpected file %s in layout", name)
+					continue
+				}
+				blobs = append(blobs, blob)
+			}
+
+			slices.Sort(blobs)
+			slices.Sort(tt.blobs)
+			if !slices.Equal(blobs, tt.blobs) {
+				t.Errorf("blobs = %v, want %v", blobs, tt.blobs)
+			}
+
+			// A complete layout is importable as the same image
+			if tt.digest == "" {
+				return
+			}
+
+			l, err := InspectReader(&buf)
+			if err != nil {
+				t.Fatalf("InspectReader() error = %v", err)
+			}
+
+			if len(l.Images) != 1 || l.Images[0].Digest != tt.digest {
+				t.Fatalf("Images = %+v, want digest %s", l.Images, tt.digest)
+			}
+
+			if want := []string{"docker.io/library/foo:dev"}; !slices.Equal(l.References(), want) {
+				t.Errorf("References() = %v, want %v", l.References(), want)
+			}
+		})
+	}
+}
+
+func TestWriteLayoutWithoutPath(t *testing.T) {
+	a, err := InspectReader(bytes.NewReader(ctrExport(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.WriteLayout(io.Discard, func(string) bool { return false }); err == nil {
+		t.Error("WriteLayout() error = nil, want an error for an archive without a path")
+	}
+}
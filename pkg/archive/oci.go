@@ -0,0 +1,220 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Media types used by OCI images.
+const (
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCILayerZstd = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Annotations used to name images within an OCI layout.
+const (
+	AnnotationImageName = "io.containerd.image.name"
+	AnnotationRefName   = "org.opencontainers.image.ref.name"
+)
+
+type ociLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+type ociIndex struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// readOCIIndex describes the images listed in index.json, verifying that every
+// blob within the layout matches its digest.
+func (a *Archive) readOCIIndex() error {
+	if b, err := a.file("oci-layout"); err == nil {
+		var layout ociLayout
+		if err := json.Unmarshal(b, &layout); err != nil {
+			return fmt.Errorf("%w: unable to parse oci-layout: %v", ErrInvalid, err)
+		}
+
+		if layout.ImageLayoutVersion != "1.0.0" {
+			return fmt.Errorf("%w: unsupported OCI layout version %q", ErrInvalid, layout.ImageLayoutVersion)
+		}
+	}
+
+	for name, e := range a.entries {
+		if !strings.HasPrefix(name, "blobs/sha256/") || e.link != "" {
+			continue
+		}
+
+		if expected := "sha256:" + path.Base(name); e.digest != expected {
+			return fmt.Errorf("%w: blob %s is corrupt, digest is %s", ErrInvalid, name, e.digest)
+		}
+	}
+
+	b, err := a.file("index.json")
+	if err != nil {
+		return err
+	}
+
+	var idx ociIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return fmt.Errorf("%w: unable to parse index.json: %v", ErrInvalid, err)
+	}
+
+	for _, desc := range idx.Manifests {
+		img := Image{
+			Digest:    desc.Digest,
+			MediaType: desc.MediaType,
+		}
+
+		if name := imageName(desc.Annotations); name != "" {
+			ref, err := NormalizeReference(name)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalid, err)
+			}
+			img.References = []string{ref}
+		}
+
+		if err := a.walkOCI(&img, desc, true); err != nil {
+			return err
+		}
+
+		a.Images = append(a.Images, img)
+	}
+
+	return a.nameFromDockerManifest()
+}
+
+// nameFromDockerManifest gives images left unnamed by index.json the repo tags
+// of the manifest.json entry with the same config, as containerd does for the
+// layouts written by docker save.
+func (a *Archive) nameFromDockerManifest() error {
+	if a.entries["manifest.json"] == nil {
+		return nil
+	}
+
+	b, err := a.file("manifest.json")
+	if err != nil {
+		return err
+	}
+
+	var mfsts []dockerManifest
+	if err := json.Unmarshal(b, &mfsts); err != nil {
+		return fmt.Errorf("%w: unable to parse manifest.json: %v", ErrInvalid, err)
+	}
+
+	for i := range a.Images {
+		img := &a.Images[i]
+		if len(img.References) > 0 || img.Config == "" {
+			continue
+		}
+
+		for _, mfst := range mfsts {
+			cfg, ok := a.entries[cleanName(mfst.Config)]
+			if !ok || cfg.digest != img.Config {
+				continue
+			}
+
+			for _, tag := range mfst.RepoTags {
+				ref, err := NormalizeReference(tag)
+				if err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalid, err)
+				}
+				img.References = append(img.References, ref)
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkOCI adds the size and layers of desc and its children to img. Manifests
+// referenced by an index may be omitted from the archive, e.g. for platforms
+// that were not saved, but everything a present manifest refers to must exist.
+func (a *Archive) walkOCI(img *Image, desc Descriptor, required bool) error {
+	e, ok := a.entries[blobName(desc.Digest)]
+	if !ok {
+		if required {
+			return fmt.Errorf("%w: blob %s not found in archive", ErrInvalid, desc.Digest)
+		}
+		return nil
+	}
+	img.Size += e.size
+
+	switch desc.MediaType {
+	case MediaTypeOCIIndex, MediaTypeDockerManifestList:
+		b, err := a.file(e.name)
+		if err != nil {
+			return err
+		}
+
+		var idx ociIndex
+		if err := json.Unmarshal(b, &idx); err != nil {
+			return fmt.Errorf("%w: unable to parse index %s: %v", ErrInvalid, desc.Digest, err)
+		}
+
+		for _, child := range idx.Manifests {
+			if err := a.walkOCI(img, child, false); err != nil {
+				return err
+			}
+		}
+	case MediaTypeOCIManifest, MediaTypeDockerManifest:
+		b, err := a.file(e.name)
+		if err != nil {
+			return err
+		}
+
+		var mfst ociManifest
+		if err := json.Unmarshal(b, &mfst); err != nil {
+			return fmt.Errorf("%w: unable to parse manifest %s: %v", ErrInvalid, desc.Digest, err)
+		}
+
+		if required {
+			img.Config = mfst.Config.Digest
+		}
+
+		for _, d := range append([]Descriptor{mfst.Config}, mfst.Layers...) {
+			c, ok := a.entries[blobName(d.Digest)]
+			if !ok {
+				return fmt.Errorf("%w: blob %s referenced by manifest %s not found in archive", ErrInvalid, d.Digest, desc.Digest)
+			}
+			img.Size += c.size
+		}
+
+		img.Layers = append(img.Layers, mfst.Layers...)
+	}
+
+	return nil
+}
+
+// imageName returns the name containerd gives an image from an OCI layout.
+// Bare tags in the ref name annotation can't be resolved to a repository and
+// are ignored.
+func imageName(annotations map[string]string) string {
+	if name := annotations[AnnotationImageName]; name != "" {
+		return name
+	}
+
+	if name := annotations[AnnotationRefName]; strings.ContainsAny(name, "/:") {
+		return name
+	}
+
+	return ""
+}
+
+func blobName(digest string) string {
+	alg, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", alg, hex)
+}
@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultDomain = "docker.io"
+	officialRepo  = "library"
+	defaultTag    = "latest"
+)
+
+// NormalizeReference returns ref in the fully qualified form containerd uses
+// when naming images, e.g. "foo:dev" becomes "docker.io/library/foo:dev" and
+// references without a tag or digest are given the "latest" tag.
+func NormalizeReference(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("reference must not be empty")
+	}
+
+	if strings.ContainsAny(ref, " \t\n") {
+		return "", fmt.Errorf("reference %q must not contain whitespace", ref)
+	}
+
+	name, digest, hasDigest := strings.Cut(ref, "@")
+
+	domain, remainder := defaultDomain, name
+	if i := strings.IndexRune(name, '/'); i != -1 {
+		d := name[:i]
+		if strings.ContainsAny(d, ".:") || d == "localhost" || strings.ToLower(d) != d {
+			domain, remainder = d, name[i+1:]
+		}
+	}
+
+	if domain == defaultDomain && !strings.ContainsRune(remainder, '/') {
+		remainder = officialRepo + "/" + remainder
+	}
+
+	repo, tag := remainder, ""
+	if i := strings.LastIndex(remainder, ":"); i > strings.LastIndex(remainder, "/") {
+		repo, tag = remainder[:i], remainder[i+1:]
+	}
+
+	if repo == "" || strings.HasSuffix(repo, "/") {
+		return "", fmt.Errorf("reference %q has an invalid repository name", ref)
+	}
+
+	if strings.ToLower(repo) != repo {
+		return "", fmt.Errorf("reference %q repository name must be lowercase", ref)
+	}
+
+	normalized := domain + "/" + repo
+	if tag != "" {
+		normalized += ":" + tag
+	}
+
+	if hasDigest {
+		return normalized + "@" + digest, nil
+	}
+
+	if tag == "" {
+		normalized += ":" + defaultTag
+	}
+
+	return normalized, nil
+}
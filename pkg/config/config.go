@@ -37,6 +37,9 @@ type Config struct {
 	// VCenterConfig represents a required set of configuration for accessing
 	// the vCenter server.
 	VCenterConfig *VCenterConfig `json:"vcenter,omitempty" yaml:"vcenter,omitempty"`
+	// RuntimeConfig represents an optional set of configuration for the
+	// container runtime images are loaded into on the Supervisor VMs.
+	RuntimeConfig *RuntimeConfig `json:"runtime,omitempty" yaml:"runtime,omitempty"`
 }
 
 // SSHConfig represents the configuration needed to SSH to a server. Each
@@ -140,3 +143,17 @@ type SSOConfig struct {
 
 	// TODO(tvs): Should the host and port be separately configurable from SSH?
 }
+
+// RuntimeConfig represents the container runtime images are loaded into on the
+// Supervisor VMs.
+type RuntimeConfig struct {
+	// Name is the runtime's client: ctr, crictl, nerdctl or docker. Defaults
+	// to ctr.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Namespace is the containerd namespace images are loaded into. Defaults
+	// to k8s.io. Ignored by docker.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// StagingDir is the directory on the VMs containers are copied to before
+	// being imported. Defaults to /tmp.
+	StagingDir string `json:"stagingDir,omitempty" yaml:"stagingDir,omitempty"`
+}
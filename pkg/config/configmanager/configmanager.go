@@ -43,6 +43,21 @@ func Load() (*config.Config, error) {
 	return LoadFrom(f)
 }
 
+// LoadProfile loads the config of the named profile. Unlike Load, an error is
+// returned if the profile has no config file.
+func LoadProfile(name string) (*config.Config, error) {
+	f, err := config.ProfileFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("config path cannot be retrieved: %w", err)
+	}
+
+	if _, err := os.Stat(f); err != nil {
+		return nil, fmt.Errorf("profile %s has no config: %w", name, err)
+	}
+
+	return LoadFrom(f)
+}
+
 // Save saves the config to the file defined by the profile
 // TODO(tvs): Use an embedded template with comments explaining
 // the config and ensure that when we save we persist the comments
@@ -80,3 +80,13 @@ func File() (string, error) {
 
 	return filepath.Join(base, "ultravisor.yaml"), nil
 }
+
+// ProfileFile gets the path for the config file of the named profile
+func ProfileFile(name string) (string, error) {
+	base, err := configBaseDir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(base, name, "ultravisor.yaml"), nil
+}
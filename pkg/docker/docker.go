@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultHost is the Docker Engine API socket used when DOCKER_HOST is unset.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// Client is a minimal client for the Docker Engine API.
+type Client struct {
+	http *http.Client
+	base string
+}
+
+// NewClient returns a Client for host, which takes the same form as
+// DOCKER_HOST: unix:///path/to/socket or tcp://host:port. An empty host uses
+// DOCKER_HOST, falling back to DefaultHost.
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse docker host %q: %w", host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		return &Client{
+			http: &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, "unix", socket)
+					},
+				},
+			},
+			// The host is ignored when dialing the socket
+			base: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &Client{
+			http: &http.Client{},
+			base: "http://" + u.Host,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+	}
+}
+
+// Save exports the images named by refs as a docker-archive tarball, as with
+// `docker save`. The caller must close the returned reader.
+func (c *Client) Save(ctx context.Context, refs ...string) (io.ReadCloser, error) {
+	q := url.Values{}
+	for _, ref := range refs {
+		q.Add("names", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/images/get?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach docker: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("unable to save %s: %s", strings.Join(refs, ", "), errorMessage(resp))
+	}
+
+	return resp.Body, nil
+}
+
+// errorMessage extracts the message from a Docker Engine API error response.
+func errorMessage(resp *http.Response) string {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var e struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(b, &e); err == nil && e.Message != "" {
+		return e.Message
+	}
+
+	if msg := strings.TrimSpace(string(b)); msg != "" {
+		return msg
+	}
+
+	return resp.Status
+}
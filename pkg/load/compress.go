@@ -0,0 +1,245 @@
+package load
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// Compression is how containers are compressed while in transit to the
+// Supervisor VMs.
+type Compression string
+
+const (
+	// NoCompression sends containers as they are.
+	NoCompression Compression = "none"
+	// GzipCompression compresses containers with gzip.
+	GzipCompression Compression = "gzip"
+	// ZstdCompression compresses containers with zstd.
+	ZstdCompression Compression = "zstd"
+)
+
+// Compressions lists the supported compressions.
+var Compressions = []Compression{NoCompression, GzipCompression, ZstdCompression}
+
+// codec compresses containers locally for a decompressor on the VMs.
+type codec struct {
+	compression Compression
+	// binary is the decompressor that must exist on the VM.
+	binary string
+	// newWriter returns a writer compressing to w.
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var codecs = map[Compression]*codec{
+	GzipCompression: {
+		compression: GzipCompression,
+		binary:      "gzip",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzip.BestSpeed)
+		},
+	},
+	ZstdCompression: {
+		compression: ZstdCompression,
+		binary:      "zstd",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+}
+
+// decompressCommand returns the command decompressing stdin to stdout on the
+// VM.
+func (c *codec) decompressCommand() string {
+	return c.binary + " -dc"
+}
+
+// pipeCommand returns a command decompressing stdin into cmd.
+func (c *codec) pipeCommand(cmd string) string {
+	return fmt.Sprintf("set -o pipefail; %s | %s", c.decompressCommand(), cmd)
+}
+
+// writeCommand returns a command decompressing stdin to file.
+func (c *codec) writeCommand(file string) string {
+	return fmt.Sprintf("%s > %s", c.decompressCommand(), remote.Quote(file))
+}
+
+// compress returns a reader of the compressed contents of r. The reader must
+// be closed once done, which stops reading from r.
+func (c *codec) compress(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := c.newWriter(pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.Copy(w, r); err != nil {
+			w.Close()
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.CloseWithError(w.Close())
+	}()
+
+	return pr
+}
+
+// preferredCodecs returns the codecs to try for the compression, in order:
+// the one requested followed by the others.
+func preferredCodecs(compression Compression) []*codec {
+	preferred := []*codec{codecs[compression]}
+	for _, c := range []Compression{ZstdCompression, GzipCompression} {
+		if c != compression {
+			preferred = append(preferred, codecs[c])
+		}
+	}
+
+	return preferred
+}
+
+// detectCodec returns the first of the preferred codecs for the compression
+// whose decompressor exists, as reported by exists, or nil if none do.
+func detectCodec(compression Compression, exists func(binary string) bool) *codec {
+	for _, c := range preferredCodecs(compression) {
+		if exists(c.binary) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// validateCompression returns an error if the compression isn't supported.
+func validateCompression(compression Compression) error {
+	if !slices.Contains(Compressions, compression) {
+		return fmt.Errorf("unknown compression %q, must be one of %v", compression, Compressions)
+	}
+
+	return nil
+}
+
+// codecFor returns the codec containers are compressed with for the VM, or nil
+// if they are sent uncompressed. The requested compression is used if its
+// decompressor exists on the VM, otherwise the first other compression that
+// can be decompressed. The codec is detected once per VM.
+func (ld *loader) codecFor(ctx context.Context, conn *remote.Conn) *codec {
+	l := zerolog.Ctx(ctx)
+	vm := conn.Server.Host
+
+	if ld.opts.Compress == NoCompression {
+		return nil
+	}
+
+	ld.mu.Lock()
+	c, ok := ld.codecs[vm]
+	ld.mu.Unlock()
+	if ok {
+		return c
+	}
+
+	found := detectCodec(ld.opts.Compress, func(binary string) bool {
+		_, _, err := conn.Run(ctx, "command -v "+binary)
+		return err == nil
+	})
+
+	switch {
+	case found == nil:
+		l.Warn().Str("address", vm).Str("compression", string(ld.opts.Compress)).Msg("no decompressor found on VM, sending uncompressed")
+	case found.compression != ld.opts.Compress:
+		l.Warn().Str("address", vm).Str("compression", string(ld.opts.Compress)).Str("fallback", string(found.compression)).Msg("decompressor not found on VM, falling back")
+	default:
+		l.Debug().Str("address", vm).Str("compression", string(found.compression)).Msg("compressing transfers to VM")
+	}
+
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	if ld.codecs == nil {
+		ld.codecs = map[string]*codec{}
+	}
+	ld.codecs[vm] = found
+
+	return found
+}
+
+// compressionCheck reports whether the VM can decompress the requested
+// compression.
+func (ld *loader) compressionCheck(ctx context.Context, conn *remote.Conn) PreflightCheck {
+	check := PreflightCheck{Name: "decompressor", Status: PassStatus}
+
+	c := ld.codecFor(ctx, conn)
+	switch {
+	case c == nil:
+		check.Status, check.Detail = WarnStatus, "no decompressor found, sending uncompressed"
+	case c.compression != ld.opts.Compress:
+		check.Status, check.Detail = WarnStatus, fmt.Sprintf("%s not found, falling back to %s", ld.opts.Compress, c.compression)
+	default:
+		check.Detail = c.binary
+	}
+
+	return check
+}
+
+// importStream imports the container read from r into the VM's container
+// runtime, compressing it in transit if possible.
+func (ld *loader) importStream(ctx context.Context, conn *remote.Conn, r io.Reader) ([]ImportedImage, error) {
+	c := ld.codecFor(ctx, conn)
+	if c == nil {
+		return ld.runtime.Import(ctx, conn, "", r)
+	}
+
+	cr := c.compress(r)
+	defer cr.Close()
+
+	stdout, err := runImport(ctx, conn, c.pipeCommand(ld.runtime.importCommand()), cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return ld.runtime.parseImport(stdout), nil
+}
+
+// copy copies the container to target on the VM, reporting its progress under
+// name. When compressing, the container is decompressed into target as it
+// arrives.
+func (ld *loader) copy(ctx context.Context, conn *remote.Conn, name string, a *archive.Archive, target string) error {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	pr := ld.track(f, name, conn.Server.Host, stat.Size())
+	defer pr.finish()
+
+	c := ld.codecFor(ctx, conn)
+	if c == nil {
+		return conn.CopyFrom(ctx, pr, stat.Size(), stat.Mode(), target)
+	}
+
+	cr := c.compress(pr)
+	defer cr.Close()
+
+	if _, stderr, err := conn.RunWithInput(ctx, c.writeCommand(target), cr); err != nil {
+		return commandError(c.binary, err, stderr)
+	}
+
+	return nil
+}
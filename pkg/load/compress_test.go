@@ -0,0 +1,109 @@
+package load
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestPreferredCodecs(t *testing.T) {
+	tests := []struct {
+		compression Compression
+		want        []Compression
+	}{
+		{compression: ZstdCompression, want: []Compression{ZstdCompression, GzipCompression}},
+		{compression: GzipCompression, want: []Compression{GzipCompression, ZstdCompression}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.compression), func(t *testing.T) {
+			var got []Compression
+			for _, c := range preferredCodecs(tt.compression) {
+				got = append(got, c.compression)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("preferredCodecs(%s) = %v, want %v", tt.compression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectCodec(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression Compression
+		binaries    []string
+		want        Compression
+	}{
+		{name: "requested", compression: ZstdCompression, binaries: []string{"gzip", "zstd"}, want: ZstdCompression},
+		{name: "fallback to gzip", compression: ZstdCompression, binaries: []string{"gzip"}, want: GzipCompression},
+		{name: "fallback to zstd", compression: GzipCompression, binaries: []string{"zstd"}, want: ZstdCompression},
+		{name: "no decompressor", compression: ZstdCompression},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := detectCodec(tt.compression, func(binary string) bool {
+				return slices.Contains(tt.binaries, binary)
+			})
+
+			var got Compression
+			if c != nil {
+				got = c.compression
+			}
+
+			if got != tt.want {
+				t.Errorf("detectCodec(%s) = %q, want %q", tt.compression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecCompress(t *testing.T) {
+	data := strings.Repeat("ultravisor", 64*1024)
+
+	decompress := map[Compression]func(io.Reader) (io.Reader, error){
+		GzipCompression: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		ZstdCompression: func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+
+	for compression, c := range codecs {
+		t.Run(string(compression), func(t *testing.T) {
+			cr := c.compress(strings.NewReader(data))
+			defer cr.Close()
+
+			compressed, err := io.ReadAll(cr)
+			if err != nil {
+				t.Fatalf("compress() error = %v", err)
+			}
+
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+			}
+
+			r, err := decompress[compression](bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != data {
+				t.Errorf("decompressed %d bytes, want %d", len(got), len(data))
+			}
+		})
+	}
+}
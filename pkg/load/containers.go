@@ -0,0 +1,137 @@
+package load
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+// ResolveContainers expands the supplied paths into a list of container files.
+// Glob patterns are expanded to their matches and directories to the .tar
+// files directly within them, while Stdin, docker:// and oci: sources are
+// passed through untouched.
+// Duplicates are removed, preserving order.
+func ResolveContainers(paths []string) ([]string, error) {
+	var (
+		containers []string
+		seen       = map[string]bool{}
+	)
+
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			containers = append(containers, p)
+		}
+	}
+
+	for _, p := range paths {
+		if isSource(p) {
+			containers = append(containers, p)
+			continue
+		}
+
+		matches := []string{p}
+		if strings.ContainsAny(p, "*?[") {
+			var err error
+			matches, err = filepath.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no containers match %q", p)
+			}
+		}
+
+		for _, match := range matches {
+			files, err := expand(match)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, f := range files {
+				add(f)
+			}
+		}
+	}
+
+	return containers, nil
+}
+
+// expand returns the .tar files within p if it is a directory, otherwise p
+// itself.
+func expand(p string) ([]string, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find container: %w", err)
+	}
+
+	if !stat.IsDir() {
+		return []string{p}, nil
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".tar") {
+			files = append(files, filepath.Join(p, e.Name()))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .tar containers found in directory %s", p)
+	}
+
+	return files, nil
+}
+
+// ResolveReferences resolves the supplied arguments into image references.
+// Arguments naming a container file are replaced by the references in it;
+// every other argument is taken as a reference. Duplicates are removed,
+// preserving order.
+func ResolveReferences(args []string) ([]string, error) {
+	var (
+		refs []string
+		seen = map[string]bool{}
+	)
+
+	add := func(ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil && fi.Mode().IsRegular() {
+			a, err := archive.Inspect(arg)
+			if err != nil {
+				return nil, fmt.Errorf("unable to inspect container %s: %w", arg, err)
+			}
+
+			if len(a.References()) == 0 {
+				return nil, fmt.Errorf("container %s has no image references", arg)
+			}
+
+			for _, ref := range a.References() {
+				add(ref)
+			}
+			continue
+		}
+
+		ref, err := archive.NormalizeReference(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %s: %w", arg, err)
+		}
+		add(ref)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,152 @@
+package load
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveContainers(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.tar", "b.tar", "notes.txt", "images/c.tar", "images/d.tar", "images/nested/e.tar", "empty/readme"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "files",
+			paths: []string{p("b.tar"), p("a.tar"), p("notes.txt")},
+			want:  []string{p("b.tar"), p("a.tar"), p("notes.txt")},
+		},
+		{
+			name:  "directory",
+			paths: []string{p("images")},
+			want:  []string{p("images/c.tar"), p("images/d.tar")},
+		},
+		{
+			name:  "glob",
+			paths: []string{p("*.tar")},
+			want:  []string{p("a.tar"), p("b.tar")},
+		},
+		{
+			name:  "duplicates",
+			paths: []string{p("a.tar"), p("*.tar"), p("a.tar")},
+			want:  []string{p("a.tar"), p("b.tar")},
+		},
+		{
+			name:  "sources",
+			paths: []string{Stdin, "docker://foo:dev", "oci:./layout:dev", "profile:lab/foo:dev", p("a.tar")},
+			want:  []string{Stdin, "docker://foo:dev", "oci:./layout:dev", "profile:lab/foo:dev", p("a.tar")},
+		},
+		{
+			name:  "sources are not deduplicated",
+			paths: []string{"docker://foo:dev", "docker://foo:dev"},
+			want:  []string{"docker://foo:dev", "docker://foo:dev"},
+		},
+		{
+			name:    "missing file",
+			paths:   []string{p("missing.tar")},
+			wantErr: "unable to find container",
+		},
+		{
+			name:    "directory without containers",
+			paths:   []string{p("empty")},
+			wantErr: "no .tar containers found",
+		},
+		{
+			name:    "glob without matches",
+			paths:   []string{p("*.tgz")},
+			wantErr: "no containers match",
+		},
+		{
+			name:    "invalid glob",
+			paths:   []string{p("[.tar")},
+			wantErr: "invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveContainers(tt.paths)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveContainers() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ResolveContainers() error = %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ResolveContainers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReferences(t *testing.T) {
+	container := filepath.Join(t.TempDir(), "foo.tar")
+	if err := os.WriteFile(container, dockerSave(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "references",
+			args: []string{"foo:dev", "localhost:5000/vmware/bar:1.2.3"},
+			want: []string{"docker.io/library/foo:dev", "localhost:5000/vmware/bar:1.2.3"},
+		},
+		{
+			name: "container",
+			args: []string{container},
+			want: []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name: "duplicates",
+			args: []string{"foo:dev", container, "docker.io/library/foo:dev"},
+			want: []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:    "invalid reference",
+			args:    []string{"Foo"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveReferences(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveReferences() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ResolveReferences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
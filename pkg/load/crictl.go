@@ -0,0 +1,87 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// crictl manages images through the kubelet's CRI endpoint. crictl can neither
+// import nor tag images, so those go through ctr in the same namespace.
+type crictl struct {
+	ctr
+}
+
+func (c *crictl) Name() string { return CrictlRuntime }
+
+// crictlImages is the output of `crictl images -o json`.
+type crictlImages struct {
+	Images []struct {
+		ID          string   `json:"id"`
+		RepoTags    []string `json:"repoTags"`
+		RepoDigests []string `json:"repoDigests"`
+		Size        string   `json:"size"`
+		Pinned      bool     `json:"pinned"`
+	} `json:"images"`
+}
+
+func (c *crictl) listCommand() string {
+	return "crictl images -o json"
+}
+
+func (c *crictl) List(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error) {
+	stdout, err := run(ctx, conn, c.listCommand())
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := parseCrictlImages(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.withLabels(ctx, conn, images)
+}
+
+func parseCrictlImages(s string) ([]RemoteImage, error) {
+	var list crictlImages
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, fmt.Errorf("unable to parse image list: %w", err)
+	}
+
+	var images []RemoteImage
+	for _, img := range list.Images {
+		// repoDigests hold the manifest digest of each repository as
+		// name@digest
+		digests := map[string]string{}
+		for _, rd := range img.RepoDigests {
+			if name, d, ok := strings.Cut(rd, "@"); ok {
+				digests[name] = d
+			}
+		}
+
+		for _, ref := range img.RepoTags {
+			name := ref
+			if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+				name = ref[:i]
+			}
+
+			images = append(images, RemoteImage{
+				Reference: ref,
+				Digest:    digests[name],
+				Size:      img.Size,
+				Pinned:    img.Pinned,
+			})
+		}
+	}
+
+	return images, nil
+}
+
+func (c *crictl) Remove(ctx context.Context, conn *remote.Conn, ref string) error {
+	_, err := run(ctx, conn, "crictl rmi "+remote.Quote(ref))
+	return err
+}
@@ -0,0 +1,86 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCrictlImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []RemoteImage
+		wantErr bool
+	}{
+		{
+			name: "images",
+			s: `{
+  "images": [
+    {
+      "id": "sha256:7a1c3e0b8a6d4b0c9a3f2e1d7c5b4a39e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3",
+      "repoTags": [
+        "docker.io/library/foo:dev",
+        "localhost:5000/vmware/foo:dev"
+      ],
+      "repoDigests": [
+        "docker.io/library/foo@sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270"
+      ],
+      "size": "2202009",
+      "uid": null,
+      "username": "",
+      "spec": null,
+      "pinned": true
+    },
+    {
+      "id": "sha256:0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b",
+      "repoTags": [],
+      "repoDigests": [
+        "docker.io/library/bar@sha256:0e3bbf5b4ca5ed5af8a5e1d5cd3a4b0a6ce0a9d2e09e6ab0e3f13f8cfb7ee1e1"
+      ],
+      "size": "11953766",
+      "uid": null,
+      "username": "",
+      "spec": null,
+      "pinned": false
+    }
+  ]
+}
+`,
+			want: []RemoteImage{
+				{
+					Reference: "docker.io/library/foo:dev",
+					Digest:    "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+					Size:      "2202009",
+					Pinned:    true,
+				},
+				{
+					Reference: "localhost:5000/vmware/foo:dev",
+					Size:      "2202009",
+					Pinned:    true,
+				},
+			},
+		},
+		{
+			name: "empty",
+			s:    `{"images":[]}`,
+		},
+		{
+			name:    "invalid",
+			s:       "IMAGE    TAG    IMAGE ID    SIZE\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCrictlImages(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCrictlImages() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCrictlImages() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// ctr loads images with containerd's ctr client.
+type ctr struct {
+	namespace string
+}
+
+func (c *ctr) Name() string { return CtrRuntime }
+
+func (c *ctr) command(args string) string {
+	return fmt.Sprintf("ctr -n %s %s", remote.Quote(c.namespace), args)
+}
+
+func (c *ctr) Import(ctx context.Context, conn *remote.Conn, file string, r io.Reader) ([]ImportedImage, error) {
+	cmd := c.importCommand()
+	if r == nil {
+		cmd = c.importFileCommand(file)
+	}
+
+	stdout, err := runImport(ctx, conn, cmd, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseImport(stdout), nil
+}
+
+// ctrUnpacking matches the lines ctr prints as it unpacks each imported
+// image, e.g. "unpacking docker.io/library/foo:dev (sha256:...)...done".
+var ctrUnpacking = regexp.MustCompile(`(?m)^unpacking (\S+) \((sha256:[0-9a-f]+)\)`)
+
+func (c *ctr) parseImport(s string) []ImportedImage {
+	var imported []ImportedImage
+	for _, m := range ctrUnpacking.FindAllStringSubmatch(s, -1) {
+		imported = append(imported, ImportedImage{Reference: m[1], Digest: m[2]})
+	}
+
+	return imported
+}
+
+func (c *ctr) exportCommand(refs []string) string {
+	return c.command("images export - " + quoteAll(refs))
+}
+
+func (c *ctr) importCommand() string {
+	return c.command("images import -")
+}
+
+func (c *ctr) importFileCommand(file string) string {
+	return c.command("images import " + remote.Quote(file))
+}
+
+func (c *ctr) listCommand() string {
+	return c.command("images ls")
+}
+
+func (c *ctr) List(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error) {
+	stdout, err := run(ctx, conn, c.listCommand())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCtrImageList(stdout)
+}
+
+// parseCtrImageList parses the output of `ctr images ls`:
+//
+//	REF TYPE DIGEST SIZE PLATFORMS LABELS
+//	docker.io/library/foo:dev application/vnd... sha256:... 2.1 MiB linux/amd64 -
+func parseCtrImageList(s string) ([]RemoteImage, error) {
+	var images []RemoteImage
+
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || (i == 0 && fields[0] == "REF") {
+			continue
+		}
+
+		// The size is printed as a value and a unit separated by a space.
+		// Columns are taken from the right of the reference so that any
+		// column ctr adds after it doesn't shift the ones that are parsed.
+		n := len(fields)
+		if n < 7 {
+			return nil, fmt.Errorf("unable to parse image list, unexpected line %q", line)
+		}
+
+		img := RemoteImage{
+			Reference: fields[0],
+			MediaType: fields[n-6],
+			Digest:    fields[n-5],
+			Size:      fields[n-4] + " " + fields[n-3],
+		}
+
+		if fields[n-2] != "-" {
+			img.Platforms = strings.Split(fields[n-2], ",")
+		}
+
+		if fields[n-1] != "-" {
+			img.Labels = map[string]string{}
+			for _, label := range strings.Split(fields[n-1], ",") {
+				k, v, _ := strings.Cut(label, "=")
+				img.Labels[k] = v
+			}
+			img.Pinned = img.Labels[PinnedLabel] == pinnedValue
+		}
+
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+// withLabels fills in the labels of images listed by a client that doesn't
+// report them, such as crictl and nerdctl, from ctr's listing.
+func (c *ctr) withLabels(ctx context.Context, conn *remote.Conn, images []RemoteImage) ([]RemoteImage, error) {
+	labelled, err := c.List(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]map[string]string{}
+	for _, img := range labelled {
+		labels[img.Reference] = img.Labels
+	}
+
+	for i, img := range images {
+		images[i].Labels = labels[img.Reference]
+		images[i].Pinned = img.Pinned || labels[img.Reference][PinnedLabel] == pinnedValue
+	}
+
+	return images, nil
+}
+
+func (c *ctr) tagCommand(source, target string) string {
+	return c.command(fmt.Sprintf("images tag --force %s %s", remote.Quote(source), remote.Quote(target)))
+}
+
+func (c *ctr) Tag(ctx context.Context, conn *remote.Conn, source, target string) error {
+	_, err := run(ctx, conn, c.tagCommand(source, target))
+	return err
+}
+
+func (c *ctr) Remove(ctx context.Context, conn *remote.Conn, ref string) error {
+	_, err := run(ctx, conn, c.command("images rm "+remote.Quote(ref)))
+	return err
+}
+
+func (c *ctr) labelCommand(ref string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	args := []string{"images label", remote.Quote(ref)}
+	for _, k := range keys {
+		args = append(args, remote.Quote(k+"="+labels[k]))
+	}
+
+	return c.command(strings.Join(args, " "))
+}
+
+func (c *ctr) Label(ctx context.Context, conn *remote.Conn, ref string, labels map[string]string) error {
+	_, err := run(ctx, conn, c.labelCommand(ref, labels))
+	return err
+}
+
+func (c *ctr) ListContent(ctx context.Context, conn *remote.Conn) (map[string]bool, error) {
+	stdout, err := run(ctx, conn, c.command("content ls -q"))
+	if err != nil {
+		return nil, err
+	}
+
+	content := map[string]bool{}
+	for _, d := range strings.Fields(stdout) {
+		content[d] = true
+	}
+
+	return content, nil
+}
+
+// InUse returns the images of the containers with running tasks. Containers
+// without a task, such as those that have exited, don't pin their image.
+func (c *ctr) InUse(ctx context.Context, conn *remote.Conn) ([]string, error) {
+	tasks, err := run(ctx, conn, c.command("tasks ls"))
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := run(ctx, conn, c.command("containers ls"))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCtrInUse(tasks, containers), nil
+}
+
+// parseCtrInUse joins the output of `ctr tasks ls`:
+//
+//	TASK PID STATUS
+//	0123abcd 4242 RUNNING
+//
+// with the output of `ctr containers ls`:
+//
+//	CONTAINER IMAGE RUNTIME
+//	0123abcd docker.io/library/foo:dev io.containerd.runc.v2
+func parseCtrInUse(tasks, containers string) []string {
+	running := map[string]bool{}
+	for _, line := range strings.Split(tasks, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[2] == "RUNNING" {
+			running[fields[0]] = true
+		}
+	}
+
+	var images []string
+	for _, line := range strings.Split(containers, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && running[fields[0]] && fields[1] != "-" {
+			images = append(images, fields[1])
+		}
+	}
+
+	return images
+}
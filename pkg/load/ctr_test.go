@@ -0,0 +1,128 @@
+package load
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCtrImageList(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []RemoteImage
+		wantErr bool
+	}{
+		{
+			name: "images",
+			s: `REF                                                TYPE                                                      DIGEST                                                                  SIZE     PLATFORMS                                                LABELS
+docker.io/library/foo:dev                          application/vnd.oci.image.manifest.v1+json                sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270 2.1 MiB  linux/amd64                                              io.cri-containerd.image=managed,io.cri-containerd.pinned=pinned
+localhost:5000/vmware/bar:1.2.3                    application/vnd.docker.distribution.manifest.list.v2+json sha256:0e3bbf5b4ca5ed5af8a5e1d5cd3a4b0a6ce0a9d2e09e6ab0e3f13f8cfb7ee1e1 11.4 MiB linux/amd64,linux/arm64                                  -
+`,
+			want: []RemoteImage{
+				{
+					Reference: "docker.io/library/foo:dev",
+					MediaType: "application/vnd.oci.image.manifest.v1+json",
+					Digest:    "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+					Size:      "2.1 MiB",
+					Platforms: []string{"linux/amd64"},
+					Labels:    map[string]string{"io.cri-containerd.image": "managed", PinnedLabel: pinnedValue},
+					Pinned:    true,
+				},
+				{
+					Reference: "localhost:5000/vmware/bar:1.2.3",
+					MediaType: "application/vnd.docker.distribution.manifest.list.v2+json",
+					Digest:    "sha256:0e3bbf5b4ca5ed5af8a5e1d5cd3a4b0a6ce0a9d2e09e6ab0e3f13f8cfb7ee1e1",
+					Size:      "11.4 MiB",
+					Platforms: []string{"linux/amd64", "linux/arm64"},
+				},
+			},
+		},
+		{
+			name: "extra column",
+			s: `REF                       EXTRA TYPE                                       DIGEST                                                                  SIZE    PLATFORMS   LABELS
+docker.io/library/foo:dev x     application/vnd.oci.image.manifest.v1+json sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270 2.1 MiB linux/amd64 -
+`,
+			want: []RemoteImage{
+				{
+					Reference: "docker.io/library/foo:dev",
+					MediaType: "application/vnd.oci.image.manifest.v1+json",
+					Digest:    "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+					Size:      "2.1 MiB",
+					Platforms: []string{"linux/amd64"},
+				},
+			},
+		},
+		{
+			name: "empty",
+			s:    "REF TYPE DIGEST SIZE PLATFORMS LABELS\n",
+		},
+		{
+			name:    "short line",
+			s:       "REF TYPE DIGEST SIZE PLATFORMS LABELS\ndocker.io/library/foo:dev sha256:5b0bcabd 2.1 MiB\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCtrImageList(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCtrImageList() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCtrImageList() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCtrInUse(t *testing.T) {
+	tasks := strings.Join([]string{
+		"TASK                                                                PID     STATUS",
+		"6f1c3e0b8a6d4b0c9a3f2e1d7c5b4a39e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3    4242    RUNNING",
+		"0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b    4343    STOPPED",
+		"1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c    4444    RUNNING",
+	}, "\n")
+	containers := strings.Join([]string{
+		"CONTAINER                                                           IMAGE                              RUNTIME",
+		"6f1c3e0b8a6d4b0c9a3f2e1d7c5b4a39e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3    docker.io/library/foo:dev          io.containerd.runc.v2",
+		"0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b    localhost:5000/vmware/bar:1.2.3    io.containerd.runc.v2",
+		"1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c    -                                  io.containerd.runc.v2",
+		"2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d    docker.io/library/baz:latest       io.containerd.runc.v2",
+	}, "\n")
+
+	tests := []struct {
+		name       string
+		tasks      string
+		containers string
+		want       []string
+	}{
+		{
+			name:       "running",
+			tasks:      tasks,
+			containers: containers,
+			want:       []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:       "no tasks",
+			tasks:      "TASK    PID    STATUS\n",
+			containers: containers,
+		},
+		{
+			name:       "no containers",
+			tasks:      tasks,
+			containers: "CONTAINER    IMAGE    RUNTIME\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCtrInUse(tt.tasks, tt.containers); !slices.Equal(got, tt.want) {
+				t.Errorf("parseCtrInUse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// dockerCLI loads images with the docker client. Docker doesn't record the
+// manifest digest of loaded images, so they can only be checked for presence.
+type dockerCLI struct{}
+
+func (d *dockerCLI) Name() string { return DockerRuntime }
+
+func (d *dockerCLI) Import(ctx context.Context, conn *remote.Conn, file string, r io.Reader) ([]ImportedImage, error) {
+	cmd := d.importCommand()
+	if r == nil {
+		cmd = d.importFileCommand(file)
+	}
+
+	stdout, err := runImport(ctx, conn, cmd, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.parseImport(stdout), nil
+}
+
+func (d *dockerCLI) parseImport(s string) []ImportedImage {
+	return normalizeImported(parseLoaded(s))
+}
+
+func (d *dockerCLI) exportCommand(refs []string) string {
+	return "docker save " + quoteAll(refs)
+}
+
+func (d *dockerCLI) importCommand() string {
+	return "docker load"
+}
+
+func (d *dockerCLI) importFileCommand(file string) string {
+	return "docker load -i " + remote.Quote(file)
+}
+
+func (d *dockerCLI) listCommand() string {
+	return "docker images --no-trunc --digests --format '{{json .}}'"
+}
+
+func (d *dockerCLI) List(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error) {
+	stdout, err := run(ctx, conn, d.listCommand())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJSONImages(stdout)
+}
+
+func (d *dockerCLI) tagCommand(source, target string) string {
+	return fmt.Sprintf("docker tag %s %s", remote.Quote(source), remote.Quote(target))
+}
+
+func (d *dockerCLI) Tag(ctx context.Context, conn *remote.Conn, source, target string) error {
+	_, err := run(ctx, conn, d.tagCommand(source, target))
+	return err
+}
+
+func (d *dockerCLI) Remove(ctx context.Context, conn *remote.Conn, ref string) error {
+	_, err := run(ctx, conn, "docker rmi "+remote.Quote(ref))
+	return err
+}
+
+// labelCommand returns an empty string as docker image labels are fixed when
+// the image is built.
+func (d *dockerCLI) labelCommand(ref string, labels map[string]string) string {
+	return ""
+}
+
+// Label fails as docker image labels are fixed when the image is built.
+func (d *dockerCLI) Label(ctx context.Context, conn *remote.Conn, ref string, labels map[string]string) error {
+	return errLabelsUnsupported
+}
+
+func (d *dockerCLI) InUse(ctx context.Context, conn *remote.Conn) ([]string, error) {
+	stdout, err := run(ctx, conn, "docker ps --no-trunc --format '{{.Image}}'")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(stdout), nil
+}
@@ -0,0 +1,84 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// ImagesOptions configures how images are listed on the Supervisor VMs.
+type ImagesOptions struct {
+	// Parallel is the maximum number of VMs that are listed concurrently.
+	// Values less than 1 list every VM at once.
+	Parallel int
+	// Runtime is the name of the container runtime to list, overriding the
+	// profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace to list, overriding the profile's
+	// runtime config. Defaults to k8s.io.
+	Namespace string
+}
+
+// VMImages holds the images known to the container runtime of a single
+// Supervisor VM.
+type VMImages struct {
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Images are the images on the VM.
+	Images []RemoteImage `json:"images,omitempty"`
+	// Err is the error encountered while listing the VM, if any.
+	Err error `json:"-"`
+}
+
+// ListImages lists the images on every Supervisor control plane VM. A VMImages
+// is returned for every VM that was attempted; the returned error joins the
+// errors of every failure.
+func ListImages(ctx context.Context, opts ImagesOptions) ([]VMImages, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Msg("beginning image listing")
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return nil, err
+	}
+
+	runtime, err := newRuntime(c, Options{Runtime: opts.Runtime, Namespace: opts.Namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	ld, vms, err := newLoader(ctx, c, runtime, Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	results := make([]VMImages, len(vms))
+	forEachVM(vms, opts.Parallel, func(i int, vm string) {
+		results[i] = VMImages{VM: vm}
+
+		conn, err := ld.connect(ctx, vm)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+
+		results[i].Images, results[i].Err = ld.listImages(ctx, conn)
+	})
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.VM, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
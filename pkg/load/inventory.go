@@ -0,0 +1,107 @@
+package load
+
+import (
+	"slices"
+	"strings"
+)
+
+// Inventory is the combined view of the images on every Supervisor VM.
+type Inventory struct {
+	// VMs are the addresses of the VMs that were listed successfully.
+	VMs []string `json:"vms"`
+	// Images are the distinct images across the VMs, ordered by reference.
+	Images []InventoryImage `json:"images"`
+}
+
+// InventoryImage is a single image and the VMs it is present on.
+type InventoryImage struct {
+	// Digest is the digest of the image's manifest or index. It is empty when
+	// the runtime does not track manifest digests.
+	Digest string `json:"digest,omitempty"`
+	// References are the names of the image across every VM.
+	References []string `json:"references"`
+	// Size is the human readable size reported by the runtime.
+	Size string `json:"size,omitempty"`
+	// Pinned is set when the image is pinned on every VM it is present on.
+	Pinned bool `json:"pinned,omitempty"`
+	// VMs are the addresses of the VMs the image is present on.
+	VMs []string `json:"vms"`
+	// Partial is set when the image is missing from some of the VMs.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// NewInventory combines the images listed on each VM. Images are identified by
+// digest, so a reference pointing at different images on different VMs
+// appears once per image, each partially present. Images without a digest are
+// identified by reference. VMs that failed to list are left out.
+func NewInventory(results []VMImages) Inventory {
+	var inv Inventory
+
+	images := map[string]*InventoryImage{}
+	var keys []string
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		inv.VMs = append(inv.VMs, r.VM)
+
+		for _, img := range r.Images {
+			key := img.Digest
+			if key == "" {
+				key = img.Reference
+			}
+
+			ii, ok := images[key]
+			if !ok {
+				ii = &InventoryImage{Digest: img.Digest, Size: img.Size, Pinned: true}
+				images[key] = ii
+				keys = append(keys, key)
+			}
+
+			if !slices.Contains(ii.References, img.Reference) {
+				ii.References = append(ii.References, img.Reference)
+			}
+			if !slices.Contains(ii.VMs, r.VM) {
+				ii.VMs = append(ii.VMs, r.VM)
+			}
+			ii.Pinned = ii.Pinned && img.Pinned
+		}
+	}
+
+	for _, key := range keys {
+		ii := images[key]
+		slices.Sort(ii.References)
+		ii.Partial = len(ii.VMs) < len(inv.VMs)
+		inv.Images = append(inv.Images, *ii)
+	}
+
+	slices.SortFunc(inv.Images, func(a, b InventoryImage) int {
+		return strings.Compare(a.References[0], b.References[0])
+	})
+
+	return inv
+}
+
+// Partial returns the images missing from some of the VMs.
+func (inv Inventory) Partial() []InventoryImage {
+	var partial []InventoryImage
+	for _, img := range inv.Images {
+		if img.Partial {
+			partial = append(partial, img)
+		}
+	}
+
+	return partial
+}
+
+// Missing returns the VMs the image is missing from.
+func (inv Inventory) Missing(img InventoryImage) []string {
+	var missing []string
+	for _, vm := range inv.VMs {
+		if !slices.Contains(img.VMs, vm) {
+			missing = append(missing, vm)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,73 @@
+package load
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestNewInventory(t *testing.T) {
+	results := []VMImages{
+		{
+			VM: "10.0.0.1",
+			Images: []RemoteImage{
+				{Reference: "docker.io/library/foo:dev", Digest: fooDigest, Size: "2.1 MiB", Pinned: true},
+				{Reference: "docker.io/library/foo:latest", Digest: fooDigest, Size: "2.1 MiB", Pinned: true},
+				{Reference: "docker.io/library/bar:dev", Digest: barDigest, Size: "11.4 MiB"},
+			},
+		},
+		{
+			VM: "10.0.0.2",
+			Images: []RemoteImage{
+				{Reference: "docker.io/library/foo:dev", Digest: fooDigest, Size: "2.1 MiB", Pinned: true},
+				// The same reference pointing at a different image
+				{Reference: "docker.io/library/bar:dev", Digest: "sha256:0123456789abcdef", Size: "11.5 MiB"},
+				// A runtime that doesn't report digests
+				{Reference: "docker.io/library/baz:dev"},
+			},
+		},
+		{VM: "10.0.0.3", Err: errors.New("unable to connect")},
+	}
+
+	want := Inventory{
+		VMs: []string{"10.0.0.1", "10.0.0.2"},
+		Images: []InventoryImage{
+			{Digest: barDigest, References: []string{"docker.io/library/bar:dev"}, Size: "11.4 MiB", VMs: []string{"10.0.0.1"}, Partial: true},
+			{Digest: "sha256:0123456789abcdef", References: []string{"docker.io/library/bar:dev"}, Size: "11.5 MiB", VMs: []string{"10.0.0.2"}, Partial: true},
+			{References: []string{"docker.io/library/baz:dev"}, VMs: []string{"10.0.0.2"}, Partial: true},
+			{
+				Digest:     fooDigest,
+				References: []string{"docker.io/library/foo:dev", "docker.io/library/foo:latest"},
+				Size:       "2.1 MiB",
+				Pinned:     true,
+				VMs:        []string{"10.0.0.1", "10.0.0.2"},
+			},
+		},
+	}
+
+	inv := NewInventory(results)
+	if !reflect.DeepEqual(inv, want) {
+		t.Fatalf("NewInventory() =\n%+v\nwant\n%+v", inv, want)
+	}
+
+	if got := inv.Partial(); len(got) != 3 {
+		t.Errorf("Partial() = %d images, want 3", len(got))
+	}
+
+	if got := inv.Missing(inv.Images[0]); !slices.Equal(got, []string{"10.0.0.2"}) {
+		t.Errorf("Missing() = %v, want [10.0.0.2]", got)
+	}
+}
+
+func TestNewInventoryPinned(t *testing.T) {
+	// An image is only pinned if it is pinned on every VM
+	inv := NewInventory([]VMImages{
+		{VM: "10.0.0.1", Images: []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest, Pinned: true}}},
+		{VM: "10.0.0.2", Images: []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest}}},
+	})
+
+	if len(inv.Images) != 1 || inv.Images[0].Pinned || inv.Images[0].Partial {
+		t.Errorf("NewInventory() images = %+v, want one unpinned image on every VM", inv.Images)
+	}
+}
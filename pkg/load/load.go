@@ -4,216 +4,771 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
-	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/errgroup"
 
-	"github.com/tvs/sshit"
+	"github.com/tvs/ultravisor/pkg/archive"
 	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/remote"
 	"github.com/tvs/ultravisor/pkg/supervisor"
-	"github.com/tvs/ultravisor/pkg/util/jumpbox"
 )
 
-func Load(ctx context.Context, container string) error {
+// Mode determines how a container is transferred to a Supervisor VM.
+type Mode string
+
+const (
+	// StreamMode pipes the container over the SSH session directly into the
+	// container runtime so nothing is written to the VM's disk.
+	StreamMode Mode = "stream"
+	// StagedMode copies the container to the VM before importing it from the
+	// copied file.
+	StagedMode Mode = "staged"
+	// DeltaMode streams only the layers missing from the VM's content store,
+	// along with the manifests and configs needed to recreate the images from
+	// the layers already present.
+	DeltaMode Mode = "delta"
+)
+
+// Modes lists the supported transfer modes.
+var Modes = []Mode{StreamMode, StagedMode, DeltaMode}
+
+// Options configures how a container is distributed to the Supervisor VMs.
+type Options struct {
+	// Parallel is the maximum number of VMs that are loaded concurrently.
+	// Values less than 1 load every VM at once.
+	Parallel int
+	// Mode is how the container is transferred to each VM. Defaults to
+	// StreamMode.
+	Mode Mode
+	// Force transfers the container even to VMs that already have every image
+	// in it.
+	Force bool
+	// Atomic restores the previous images on every VM if loading to any VM
+	// fails, so the VMs never run different versions of an image.
+	Atomic bool
+	// Tags are additional references given to the imported images on every
+	// VM.
+	Tags []Tag
+	// As is an additional reference given to the image on every VM when the
+	// containers hold a single image reference.
+	As string
+	// Pin protects the imported images, and any tags given to them, from the
+	// kubelet's image garbage collection.
+	Pin bool
+	// Strategy is how containers reach the VMs. Defaults to DirectStrategy.
+	Strategy Strategy
+	// Compress is how containers are compressed in transit, falling back to
+	// another compression, or none, on VMs missing the decompressor.
+	// Defaults to NoCompression.
+	Compress Compression
+	// StagingDir is the directory on the VMs containers are copied to before
+	// being imported, overriding the profile's runtime config. Defaults to
+	// /tmp.
+	StagingDir string
+	// Runtime is the name of the container runtime images are loaded into,
+	// overriding the profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace images are loaded into,
+	// overriding the profile's runtime config. Defaults to k8s.io.
+	Namespace string
+	// Progress, if set, is called with the progress of each transfer as
+	// bytes are sent, and once more when the transfer finishes. It is called
+	// concurrently for transfers to different VMs.
+	Progress func(Progress)
+	// Stdin is read for the container named "-". When it is the only
+	// container and is streamed, it is sent to every VM at once; otherwise it
+	// is first spilled to a temporary file.
+	Stdin io.Reader
+}
+
+// Result holds the outcome of loading a container onto a single Supervisor
+// VM.
+type Result struct {
+	// Container is the path of the container that was loaded.
+	Container string `json:"container"`
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Duration is how long the copy, import and verification took for the
+	// VM.
+	Duration time.Duration `json:"duration"`
+	// Skipped is set when the VM already had every image in the container,
+	// so nothing was transferred.
+	Skipped bool `json:"skipped,omitempty"`
+	// Imported are the images the container runtime reported importing.
+	Imported []ImportedImage `json:"imported,omitempty"`
+	// Verifications are the results of checking each reference in the
+	// container once imported.
+	Verifications []Verification `json:"verifications,omitempty"`
+	// RolledBack is set when the VM was returned to its previous images
+	// because an atomic load failed.
+	RolledBack bool `json:"rolledBack,omitempty"`
+	// Err is the error encountered while loading to the VM, if any.
+	Err error `json:"-"`
+
+	rollback *rollback
+}
+
+// Load transfers each container to every Supervisor control plane VM and
+// imports it into the container runtime. The Supervisor info and connections
+// are resolved once and shared by every container. Every VM is checked before
+// anything is sent, and nothing is loaded unless every VM passes; a
+// *PreflightError holding the report is returned otherwise. Up to
+// opts.Parallel VMs are loaded concurrently, each receiving the containers in
+// order. A Result is returned for every container and VM that was attempted,
+// regardless of whether loading to other VMs failed; the returned error joins
+// the errors of every failure.
+func Load(ctx context.Context, containers []string, opts Options) ([]Result, error) {
 	l := zerolog.Ctx(ctx)
 	c := config.Ctx(ctx)
 
 	l.Debug().Interface("config", c).Msg("beginning load")
 
-	if err := supervisor.ValidateConfig(c); err != nil {
-		l.Error().Err(err).Any("config", c).Msg("invalid config")
-		return err
+	p, err := prepare(ctx, c, containers, opts, true)
+	if err != nil {
+		return nil, err
 	}
+	defer p.cleanup()
+	opts = p.opts
 
-	var j *sshit.Client
-	if c.JumpboxConfig != nil {
-		var (
-			cleanup func()
-			err     error
-		)
+	ld, vms, err := newLoader(ctx, c, p.runtime, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
 
-		j, cleanup, err = jumpbox.JumpboxClient(ctx, c.JumpboxConfig)
-		if err != nil {
-			return err
+	report := ld.preflight(ctx, vms, p.archives)
+	for _, r := range report {
+		for _, check := range r.Checks {
+			if check.Status == WarnStatus {
+				l.Warn().Str("address", r.VM).Str("check", check.Name).Str("detail", check.Detail).Msg("preflight warning")
+			}
 		}
+	}
 
-		defer cleanup()
+	if !Go(report) {
+		return nil, &PreflightError{Results: report}
 	}
+	l.Debug().Int("vms", len(vms)).Msg("preflight passed")
 
-	// TODO(tvs): Ensure container file _is_ a container file
+	var results []Result
+	if p.stream {
+		r, err := openSource(ctx, containers[0], opts.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
 
-	// TODO(tvs): Preserve jumpbox session across commands so we can just
-	// change our tunnel for each VM
-	supervisorInfo, err := supervisor.InfoWithJumpbox(ctx, j)
-	if err != nil {
-		l.Error().Err(err).Msg("unable to retrieve Supervisor info")
-		return fmt.Errorf("unable to retrieve Supervisor info: %w", err)
+		results = ld.loadStream(ctx, vms, containers[0], r)
+	} else {
+		results = ld.loadAll(ctx, vms, containers, p.archives)
 	}
 
-	target := filepath.Join("/tmp", filepath.Base(container))
-	for _, vm := range supervisorInfo.VMs {
-		l.Debug().Str("address", vm).Str("file", container).Str("target", target).Msg("copying file to host")
-		if err := copyToVM(ctx, c, vm, supervisorInfo.Password, j, container, target); err != nil {
-			l.Error().Err(err).Str("address", vm).Str("file", container).Msg("error copying file to vm")
-			return err
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s on %s: %w", r.Container, r.VM, r.Err))
 		}
+	}
 
-		l.Debug().Str("address", vm).Str("file", container).Msg("load to container runtime")
-		if err := loadToCtr(ctx, c, vm, supervisorInfo.Password, j, target); err != nil {
-			l.Error().Err(err).Str("address", vm).Str("file", target).Msg("error loading file into ctr")
-			return err
+	if opts.Atomic {
+		if err := finishAtomic(ctx, results, len(errs) > 0); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	return results, errors.Join(errs...)
 }
 
-func copyToVM(ctx context.Context, c *config.Config, host, password string, jumpbox *sshit.Client, source, target string) (err error) {
+// prepared holds the validated options and inspected containers of a load.
+type prepared struct {
+	opts     Options
+	runtime  Runtime
+	archives []*archive.Archive
+	// stream is set when the only container is streamed through to the VMs
+	// without being inspected first.
+	stream bool
+	// spilled are the temporary files source containers were written to.
+	spilled []string
+}
+
+// cleanup removes the temporary files of spilled containers.
+func (p *prepared) cleanup() {
+	for _, f := range p.spilled {
+		os.Remove(f)
+	}
+}
+
+// prepare validates the options, filling in their defaults, and inspects every
+// container to catch typos and unusable files before anything is sent to the
+// VMs. Source containers are spilled to disk to be inspected when
+// spillSources is set. Otherwise local sources are inspected as they are read,
+// and those exported from Docker or another profile are left uninspected with
+// a nil archive. The prepared load must be cleaned up once done.
+func prepare(ctx context.Context, c *config.Config, containers []string, opts Options, spillSources bool) (p *prepared, err error) {
 	l := zerolog.Ctx(ctx)
 
-	// Start the tunnel if we need it
-	var endpoint sshit.Endpoint
-	if c.JumpboxConfig != nil {
-		// TODO(tvs): Extract this to utility function
-		tunnel := sshit.NewForwardTunnel(ctx,
-			sshit.Endpoint{Host: "localhost", Port: 0},
-			sshit.Endpoint{Host: host, Port: 22})
+	if opts.Mode == "" {
+		opts.Mode = StreamMode
+	}
+
+	if !slices.Contains(Modes, opts.Mode) {
+		return nil, fmt.Errorf("unknown transfer mode %q, must be one of %v", opts.Mode, Modes)
+	}
+
+	if opts.Strategy == "" {
+		opts.Strategy = DirectStrategy
+	}
+
+	if !slices.Contains(Strategies, opts.Strategy) {
+		return nil, fmt.Errorf("unknown strategy %q, must be one of %v", opts.Strategy, Strategies)
+	}
+
+	if opts.Strategy == RelayStrategy && opts.Mode == DeltaMode {
+		return nil, fmt.Errorf("delta mode can't be relayed, as the layers missing differ between VMs")
+	}
+
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no containers to load")
+	}
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return nil, err
+	}
+
+	if opts.Compress == "" {
+		opts.Compress = NoCompression
+	}
+
+	if err := validateCompression(opts.Compress); err != nil {
+		return nil, err
+	}
+
+	if opts.StagingDir == "" && c.RuntimeConfig != nil {
+		opts.StagingDir = c.RuntimeConfig.StagingDir
+	}
+
+	if opts.StagingDir == "" {
+		opts.StagingDir = "/tmp"
+	}
+
+	if !path.IsAbs(opts.StagingDir) {
+		return nil, fmt.Errorf("staging directory %s must be an absolute path", opts.StagingDir)
+	}
+
+	runtime, err := newRuntime(c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The runtime may come from the profile rather than a flag
+	if opts.Pin && runtime.Name() == DockerRuntime {
+		return nil, fmt.Errorf("images can't be pinned with the %s runtime, as it can't label them", DockerRuntime)
+	}
+
+	if countStdin(containers) > 1 {
+		return nil, fmt.Errorf("stdin may only be loaded once")
+	}
+
+	p = &prepared{
+		opts:     opts,
+		runtime:  runtime,
+		archives: make([]*archive.Archive, len(containers)),
+		stream:   streamsSource(containers, opts),
+	}
 
-		if err = tunnel.Bind(jumpbox); err != nil {
-			l.Error().Err(err).Str("host", host).Msg("unable to establish tunnel to Supervisor VM")
-			return fmt.Errorf("unable to establish tunnel to Supervisor VM: %w", err)
+	defer func() {
+		if err != nil {
+			p.cleanup()
 		}
+	}()
 
-		defer func() {
-			if tErr := tunnel.Close(); tErr != nil {
-				l.Error().Errs("err", tErr).Msg("unable to close tunnel to vCenter")
-				if err == nil {
-					err = errors.Join(tErr...)
-				}
+	if p.stream {
+		return p, nil
+	}
+
+	for i, container := range containers {
+		var a *archive.Archive
+		switch {
+		case isSource(container) && !spillSources:
+			if a, err = inspectSource(ctx, container, opts.Stdin); err != nil {
+				return p, err
 			}
-		}()
+			if a == nil {
+				l.Debug().Str("file", container).Msg("container not inspected")
+				continue
+			}
+		case isSource(container):
+			f, err := spill(ctx, container, opts.Stdin)
+			if err != nil {
+				return p, err
+			}
+			p.spilled = append(p.spilled, f)
 
-		endpoint = sshit.Endpoint{Host: tunnel.Local().Host, Port: tunnel.Local().Port}
-	} else {
-		// TODO(tvs): Configurable ports for Supervisor VMs?
-		endpoint = sshit.Endpoint{Host: host, Port: 22}
+			if a, err = archive.Inspect(f); err != nil {
+				l.Error().Err(err).Str("file", container).Msg("unable to inspect container")
+				return p, fmt.Errorf("unable to inspect container %s: %w", container, err)
+			}
+		default:
+			if a, err = archive.Inspect(container); err != nil {
+				l.Error().Err(err).Str("file", container).Msg("unable to inspect container")
+				return p, fmt.Errorf("unable to inspect container %s: %w", container, err)
+			}
+		}
+		l.Debug().Str("file", container).Strs("references", a.References()).Msg("inspected container")
+
+		p.archives[i] = a
 	}
 
-	// TODO(tvs): Extract to utility functions
-	var timeout time.Duration
-	if c.VCenterConfig.SSH.Timeout == nil {
-		timeout = 60 * time.Second
-	} else {
-		timeout = c.VCenterConfig.SSH.Timeout.Duration
+	// Tags may refer to images in the containers that weren't inspected
+	if !slices.Contains(p.archives, nil) {
+		if err := checkTags(p.archives, opts); err != nil {
+			return p, err
+		}
 	}
 
-	cfg := &ssh.ClientConfig{
-		User:            "root",
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		// TODO(tvs): Separate timeout for Supervisor
-		Timeout: timeout,
+	return p, nil
+}
+
+// newRuntime returns the runtime selected by opts, falling back to the
+// profile's runtime config.
+func newRuntime(c *config.Config, opts Options) (Runtime, error) {
+	name, namespace := opts.Runtime, opts.Namespace
+	if c.RuntimeConfig != nil {
+		if name == "" {
+			name = c.RuntimeConfig.Name
+		}
+		if namespace == "" {
+			namespace = c.RuntimeConfig.Namespace
+		}
 	}
 
-	l.Debug().Any("endpoint", endpoint).Msg("copying file through scp")
-	ssh := sshit.Client{
-		Config: cfg,
-		Server: endpoint,
+	return NewRuntime(name, namespace)
+}
+
+// loadAll loads every container to every VM. Results are ordered by
+// container, then by VM.
+func (ld *loader) loadAll(ctx context.Context, vms, containers []string, archives []*archive.Archive) []Result {
+	results := make([]Result, len(archives)*len(vms))
+
+	if ld.opts.Strategy == RelayStrategy && len(vms) > 1 {
+		rl, err := ld.startRelay(ctx, vms, containers, archives)
+		if rl != nil {
+			defer func() {
+				if err := rl.stop(ctx); err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Msg("unable to clean up relay VM")
+				}
+			}()
+		}
+
+		if err != nil {
+			for j, container := range containers {
+				for i, vm := range vms {
+					results[j*len(vms)+i] = Result{Container: container, VM: vm, Err: fmt.Errorf("unable to start relay: %w", err)}
+				}
+			}
+			return results
+		}
 	}
 
-	if err := ssh.Connect(ctx); err != nil {
-		l.Error().Err(err).Msg("unable to initiate SSH connection")
-		return fmt.Errorf("unable to initiate SSH connection: %w", err)
+	// Every VM records the same provenance for a container
+	provenances := make([]Provenance, len(archives))
+	for j, a := range archives {
+		provenances[j] = newProvenance(containers[j], a.Digest)
 	}
 
-	defer func() {
-		if tErr := ssh.Close(); tErr != nil {
-			l.Error().Err(tErr).Msg("unable to close SSH session")
-			if err == nil {
-				err = fmt.Errorf("unable to close SSH session: %w", tErr)
+	forEachVM(vms, ld.opts.Parallel, func(i int, vm string) {
+		for j, a := range archives {
+			r := ld.loadVM(ctx, vm, containers[j], a, provenances[j])
+			results[j*len(vms)+i] = r
+		}
+	})
+
+	return results
+}
+
+// finishAtomic rolls back every VM that was loaded if any VM failed, or
+// discards the preserved images otherwise. Rollback happens even if ctx has
+// been cancelled.
+func finishAtomic(ctx context.Context, results []Result, failed bool) error {
+	l := zerolog.Ctx(ctx)
+	ctx = context.WithoutCancel(ctx)
+
+	// Roll back in reverse so references shared by several containers end up
+	// at their original images
+	var errs []error
+	for i := len(results) - 1; i >= 0; i-- {
+		r := &results[i]
+		if r.rollback == nil {
+			continue
+		}
+
+		if !failed {
+			if err := r.rollback.commit(ctx); err != nil {
+				l.Error().Err(err).Str("address", r.VM).Str("file", r.Container).Msg("unable to remove images preserved for rollback")
+				errs = append(errs, fmt.Errorf("%s on %s: %w", r.Container, r.VM, err))
 			}
+			continue
 		}
-		ssh.Close()
-	}()
 
-	return ssh.Copy(source, target)
+		l.Info().Str("address", r.VM).Str("file", r.Container).Msg("rolling back images")
+		if err := r.rollback.restore(ctx); err != nil {
+			l.Error().Err(err).Str("address", r.VM).Str("file", r.Container).Msg("unable to roll back images")
+			errs = append(errs, fmt.Errorf("%s on %s: unable to roll back: %w", r.Container, r.VM, err))
+			continue
+		}
+		r.RolledBack = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// loader holds the state shared by every container and VM during a single
+// load.
+type loader struct {
+	config   *config.Config
+	manager  *remote.Manager
+	runtime  Runtime
+	password string
+	// controlPlane is the address of the Supervisor's control plane.
+	controlPlane string
+	opts         Options
+	// relay is set when containers are relayed through one of the VMs.
+	relay *relay
+
+	mu sync.Mutex
+	// codecs are the codecs detected for each VM, nil when sending
+	// uncompressed.
+	codecs map[string]*codec
+}
+
+// newLoader connects to the Supervisor, returning a loader sharing the
+// connections along with the addresses of the control plane VMs. The loader
+// must be closed once done.
+func newLoader(ctx context.Context, c *config.Config, runtime Runtime, opts Options) (*loader, []string, error) {
+	l := zerolog.Ctx(ctx)
+
+	m, err := remote.NewManager(ctx, c.JumpboxConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	supervisorInfo, err := supervisor.InfoWithManager(ctx, m)
+	if err != nil {
+		l.Error().Err(err).Msg("unable to retrieve Supervisor info")
+		if cErr := m.Close(); cErr != nil {
+			l.Error().Err(cErr).Msg("unable to close remote connections")
+		}
+		return nil, nil, fmt.Errorf("unable to retrieve Supervisor info: %w", err)
+	}
+
+	return &loader{
+		config:       c,
+		manager:      m,
+		runtime:      runtime,
+		password:     supervisorInfo.Password,
+		controlPlane: supervisorInfo.ControlPlane,
+		opts:         opts,
+	}, supervisorInfo.VMs, nil
+}
+
+// close closes every connection opened by the loader.
+func (ld *loader) close(ctx context.Context) {
+	if err := ld.manager.Close(); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("unable to close remote connections")
+	}
 }
 
-func loadToCtr(ctx context.Context, c *config.Config, host, password string, jumpbox *sshit.Client, file string) (err error) {
+// connect returns the connection to the VM.
+func (ld *loader) connect(ctx context.Context, vm string) (*remote.Conn, error) {
+	conn, err := ld.manager.Conn(ctx, supervisor.VMEndpoint(vm), supervisor.VMClientConfig(ld.config, ld.password))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("address", vm).Msg("unable to connect to Supervisor VM")
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// forEachVM calls fn for every VM, running up to parallel calls concurrently.
+// Values of parallel less than 1 run every call at once.
+func forEachVM(vms []string, parallel int, fn func(i int, vm string)) {
+	var g errgroup.Group
+	if parallel > 0 {
+		g.SetLimit(parallel)
+	}
+
+	for i, vm := range vms {
+		i, vm := i, vm
+		g.Go(func() error {
+			// Errors are collected per VM by fn rather than returned so a
+			// failure on one VM doesn't cancel the others.
+			fn(i, vm)
+			return nil
+		})
+	}
+
+	// Nothing returns an error from the group itself
+	_ = g.Wait()
+}
+
+// loadVM transfers the container to the VM, imports it, records its
+// provenance and verifies the result, sharing a single SSH connection between
+// every step.
+func (ld *loader) loadVM(ctx context.Context, vm, container string, a *archive.Archive, p Provenance) (r Result) {
 	l := zerolog.Ctx(ctx)
+	start := time.Now()
+
+	r = Result{Container: container, VM: vm}
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
 
-	// Start the tunnel if we need it
-	var endpoint sshit.Endpoint
-	if c.JumpboxConfig != nil {
-		// TODO(tvs): Extract this to utility function
-		tunnel := sshit.NewForwardTunnel(ctx,
-			sshit.Endpoint{Host: "localhost", Port: 0},
-			sshit.Endpoint{Host: host, Port: 22})
+	conn, err := ld.connect(ctx, vm)
+	if err != nil {
+		r.Err = err
+		return r
+	}
 
-		if err = tunnel.Bind(jumpbox); err != nil {
-			l.Error().Err(err).Str("host", host).Msg("unable to establish tunnel to Supervisor VM")
-			return fmt.Errorf("unable to establish tunnel to Supervisor VM: %w", err)
+	tags, err := tagsFor(a, ld.opts)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	skip := false
+	if !ld.opts.Force || ld.opts.Atomic {
+		l.Debug().Str("address", vm).Msg("checking for existing images")
+		images, err := ld.listImages(ctx, conn)
+		if err != nil {
+			l.Error().Err(err).Str("address", vm).Msg("unable to check for existing images")
+			r.Err = err
+			return r
 		}
 
-		defer func() {
-			if tErr := tunnel.Close(); tErr != nil {
-				l.Error().Errs("err", tErr).Msg("unable to close tunnel to vCenter")
-				if err == nil {
-					err = errors.Join(tErr...)
-				}
+		if !ld.opts.Force && alreadyLoaded(expectations(a, nil), images) {
+			l.Debug().Str("address", vm).Msg("images already present, skipping transfer")
+			skip = true
+		}
+
+		if ld.opts.Atomic {
+			refs := a.References()
+			for _, t := range tags {
+				refs = append(refs, t.Target)
 			}
-		}()
 
-		endpoint = sshit.Endpoint{Host: tunnel.Local().Host, Port: tunnel.Local().Port}
-	} else {
-		// TODO(tvs): Configurable ports for Supervisor VMs?
-		endpoint = sshit.Endpoint{Host: host, Port: 22}
+			l.Debug().Str("address", vm).Msg("preserving existing images for rollback")
+			if r.rollback, r.Err = prepareRollback(ctx, ld.runtime, conn, refs, images); r.Err != nil {
+				return r
+			}
+		}
 	}
 
-	// TODO(tvs): Extract to utility functions
-	var timeout time.Duration
-	if c.VCenterConfig.SSH.Timeout == nil {
-		timeout = 60 * time.Second
-	} else {
-		timeout = c.VCenterConfig.SSH.Timeout.Duration
+	if !skip {
+		if r.Imported, r.Err = ld.transfer(ctx, conn, container, a); r.Err != nil {
+			return r
+		}
+	}
+
+	if len(tags) > 0 {
+		l.Debug().Str("address", vm).Any("tags", tags).Msg("tagging images")
+		if r.Err = applyTags(ctx, ld.runtime, conn, tags); r.Err != nil {
+			return r
+		}
+	}
+
+	if !skip {
+		if r.Err = ld.recordProvenance(ctx, conn, verifiedReferences(a, tags), p); r.Err != nil {
+			return r
+		}
+	}
+
+	if ld.opts.Pin {
+		if r.Err = ld.pin(ctx, conn, verifiedReferences(a, tags), true); r.Err != nil {
+			return r
+		}
+	}
+
+	l.Debug().Str("address", vm).Msg("verifying images")
+	images, err := ld.listImages(ctx, conn)
+	if err != nil {
+		l.Error().Err(err).Str("address", vm).Msg("unable to verify images")
+		r.Err = err
+		return r
 	}
 
-	cfg := &ssh.ClientConfig{
-		User:            "root",
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		// TODO(tvs): Separate timeout for Supervisor
-		Timeout: timeout,
+	r.Skipped = skip
+	r.Verifications, r.Err = verify(expectations(a, tags), images)
+	return r
+}
+
+// listImages retrieves the images known to the VM's container runtime.
+func (ld *loader) listImages(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error) {
+	images, err := ld.runtime.List(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list images: %w", err)
 	}
 
-	l.Debug().Any("endpoint", endpoint).Msg("copying file through scp")
-	ssh := sshit.Client{
-		Config: cfg,
-		Server: endpoint,
+	return images, nil
+}
+
+// transfer sends the container to the VM and imports it, reporting the progress
+// of the transfer under name. When streaming is not supported by the VM's
+// container runtime the staged mode is used instead.
+func (ld *loader) transfer(ctx context.Context, conn *remote.Conn, name string, a *archive.Archive) (imported []ImportedImage, err error) {
+	l := zerolog.Ctx(ctx).With().Str("runtime", ld.runtime.Name()).Logger()
+	vm := conn.Server.Host
+	container := a.Path
+
+	if ld.relay != nil {
+		if imported, err = ld.relay.transfer(ctx, ld, conn, name, a); err != nil {
+			l.Error().Err(err).Str("address", vm).Str("file", container).Msg("error relaying file into container runtime")
+		}
+		return imported, err
+	}
+
+	if ld.opts.Mode != StagedMode {
+		lister, delta := ld.runtime.(ContentLister)
+		if ld.opts.Mode == DeltaMode && !delta {
+			l.Warn().Str("address", vm).Msg("runtime cannot list its content, streaming every layer")
+		}
+
+		if delta {
+			l.Debug().Str("address", vm).Str("file", container).Msg("streaming missing layers to container runtime")
+			imported, err = ld.streamDelta(ctx, lister, conn, name, a)
+		} else {
+			l.Debug().Str("address", vm).Str("file", container).Msg("streaming file to container runtime")
+			imported, err = ld.stream(ctx, conn, name, a)
+		}
+
+		if !errors.Is(err, errStreamUnsupported) {
+			if err != nil {
+				l.Error().Err(err).Str("address", vm).Str("file", container).Msg("error streaming file into container runtime")
+			}
+			return imported, err
+		}
+
+		l.Warn().Str("address", vm).Msg("container runtime cannot import from stdin, falling back to staged mode")
 	}
 
-	if err := ssh.Connect(ctx); err != nil {
-		l.Error().Err(err).Msg("unable to initiate SSH connection")
-		return fmt.Errorf("unable to initiate SSH connection: %w", err)
+	target, err := ld.stage(ctx, conn, container)
+	if err != nil {
+		l.Error().Err(err).Str("address", vm).Str("file", container).Msg("error creating staged file")
+		return nil, err
 	}
 
+	// Remove the staged file even if the copy or import fails or the load is
+	// interrupted
 	defer func() {
-		if tErr := ssh.Close(); tErr != nil {
-			l.Error().Err(tErr).Msg("unable to close SSH session")
+		if _, stderr, rErr := conn.Run(context.WithoutCancel(ctx), "rm -f "+remote.Quote(target)); rErr != nil {
+			l.Error().Err(rErr).Str("address", vm).Str("file", target).Str("stderr", stderr).Msg("unable to remove staged file")
 			if err == nil {
-				err = fmt.Errorf("unable to close SSH session: %w", tErr)
+				err = fmt.Errorf("unable to remove staged file: %w", rErr)
 			}
 		}
-		ssh.Close()
 	}()
 
-	_, stderr, err := ssh.Run(fmt.Sprintf("ctr -n k8s.io images import %s", file))
+	l.Debug().Str("address", vm).Str("file", container).Str("target", target).Msg("copying file to host")
+	if err := ld.copy(ctx, conn, name, a, target); err != nil {
+		l.Error().Err(err).Str("address", vm).Str("file", container).Msg("error copying file to vm")
+		return nil, err
+	}
+
+	l.Debug().Str("address", vm).Str("file", container).Msg("load to container runtime")
+	imported, err = ld.runtime.Import(ctx, conn, target, nil)
+	if err != nil {
+		l.Error().Err(err).Str("address", vm).Str("file", target).Msg("error loading file into container runtime")
+		return nil, err
+	}
+
+	return imported, nil
+}
+
+// stagingTemplate returns the mktemp template of the location a container is
+// copied to on the VMs when staged.
+func (ld *loader) stagingTemplate(container string) string {
+	return path.Join(ld.opts.StagingDir, filepath.Base(container)+".XXXXXXXXXX")
+}
+
+// stage creates a uniquely named file on the VM for the container to be
+// copied to, so loads sharing the staging directory cannot clobber each
+// other's files.
+func (ld *loader) stage(ctx context.Context, conn *remote.Conn, container string) (string, error) {
+	stdout, stderr, err := conn.Run(ctx, "mktemp "+remote.Quote(ld.stagingTemplate(container)))
+	if err != nil {
+		return "", commandError("mktemp", err, stderr)
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+// stream pipes the container to the VM's container runtime.
+func (ld *loader) stream(ctx context.Context, conn *remote.Conn, name string, a *archive.Archive) ([]ImportedImage, error) {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open container file: %w", err)
+	}
+	defer f.Close()
+
+	pr := ld.track(f, name, conn.Server.Host, a.Size)
+	defer pr.finish()
+
+	return ld.importStream(ctx, conn, pr)
+}
+
+// streamDelta streams an OCI layout of the container to the VM's container
+// runtime, leaving out the layers already in its content store.
+func (ld *loader) streamDelta(ctx context.Context, lister ContentLister, conn *remote.Conn, name string, a *archive.Archive) ([]ImportedImage, error) {
+	l := zerolog.Ctx(ctx)
+
+	content, err := lister.ListContent(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list content: %w", err)
+	}
+
+	sizes := map[string]int64{}
+	for _, img := range a.Images {
+		for _, layer := range img.Layers {
+			sizes[layer.Digest] = layer.Size
+		}
+	}
+
+	// Only layers are offered to skip; everything else is always sent
+	var skipped, saved int64
+	skip := func(digest string) bool {
+		if !content[digest] {
+			return false
+		}
+
+		skipped++
+		saved += sizes[digest]
+		return true
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(a.WriteLayout(pw, skip))
+	}()
+	defer pr.Close()
+
+	// The size of the layout depends on the layers skipped so is unknown
+	tracked := ld.track(pr, name, conn.Server.Host, -1)
+	defer tracked.finish()
+
+	imported, err := ld.importStream(ctx, conn, tracked)
 	if err != nil {
-		l.Error().Err(err).Str("stderr", stderr).Msg("unable to load container")
-		return err
+		return nil, err
 	}
 
-	return nil
+	l.Debug().Str("address", conn.Server.Host).Int64("layers", skipped).Int64("bytes", saved).Msg("skipped layers already present")
+	return imported, nil
 }
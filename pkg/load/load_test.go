@@ -0,0 +1,94 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/util/ptr"
+)
+
+// supervisorConfig is a profile that passes validation without being reachable.
+func supervisorConfig() *config.Config {
+	return &config.Config{
+		VCenterConfig: &config.VCenterConfig{
+			SSH: &config.SSHConfig{Host: "vcenter.local", Port: ptr.To(22), User: "root", Password: ptr.To("password")},
+		},
+	}
+}
+
+func TestPrepareTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []Tag
+		as      string
+		wantErr string
+	}{
+		{
+			name: "tag",
+			tags: []Tag{{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"}},
+		},
+		{
+			name: "as",
+			as:   "localhost:5000/vmware/foo:dev",
+		},
+		{
+			name:    "unknown tag source",
+			tags:    []Tag{{Source: "docker.io/library/bar:dev", Target: "localhost:5000/vmware/bar:dev"}},
+			wantErr: "tag source docker.io/library/bar:dev is not a reference",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Stdin: bytes.NewReader(dockerSave(t)), Tags: tt.tags, As: tt.as}
+
+			// Tagged streams are inspected before anything is transferred
+			p, err := prepare(context.Background(), supervisorConfig(), []string{Stdin}, opts, true)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("prepare() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("prepare() error = %v", err)
+			}
+			defer p.cleanup()
+
+			if p.stream || p.archives[0] == nil {
+				t.Errorf("prepare() streamed the container without inspecting it")
+			}
+		})
+	}
+}
+
+func TestPreparePin(t *testing.T) {
+	tests := []struct {
+		runtime string
+		wantErr bool
+	}{
+		{runtime: CtrRuntime},
+		{runtime: CrictlRuntime},
+		{runtime: NerdctlRuntime},
+		{runtime: DockerRuntime, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.runtime, func(t *testing.T) {
+			opts := Options{Runtime: tt.runtime, Pin: true, Stdin: bytes.NewReader(dockerSave(t))}
+
+			p, err := prepare(context.Background(), supervisorConfig(), []string{Stdin}, opts, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepare() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if p != nil {
+				p.cleanup()
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// nerdctl loads images with nerdctl. Blobs are listed with ctr, which shares
+// nerdctl's content store.
+type nerdctl struct {
+	ctr
+}
+
+func (n *nerdctl) Name() string { return NerdctlRuntime }
+
+func (n *nerdctl) command(args string) string {
+	return fmt.Sprintf("nerdctl -n %s %s", remote.Quote(n.namespace), args)
+}
+
+func (n *nerdctl) Import(ctx context.Context, conn *remote.Conn, file string, r io.Reader) ([]ImportedImage, error) {
+	cmd := n.importCommand()
+	if r == nil {
+		cmd = n.importFileCommand(file)
+	}
+
+	stdout, err := runImport(ctx, conn, cmd, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.parseImport(stdout), nil
+}
+
+func (n *nerdctl) parseImport(s string) []ImportedImage {
+	return normalizeImported(parseLoaded(s))
+}
+
+func (n *nerdctl) exportCommand(refs []string) string {
+	return n.command("save " + quoteAll(refs))
+}
+
+func (n *nerdctl) importCommand() string {
+	return n.command("load")
+}
+
+func (n *nerdctl) importFileCommand(file string) string {
+	return n.command("load -i " + remote.Quote(file))
+}
+
+// nerdctlImage is a line of `nerdctl images --format '{{json .}}'`.
+type nerdctlImage struct {
+	Repository string `json:"Repository"`
+	Tag        string `json:"Tag"`
+	Digest     string `json:"Digest"`
+	Platform   string `json:"Platform"`
+	Size       string `json:"Size"`
+}
+
+func (n *nerdctl) listCommand() string {
+	return n.command("images --no-trunc --format '{{json .}}'")
+}
+
+func (n *nerdctl) List(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error) {
+	stdout, err := run(ctx, conn, n.listCommand())
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := parseJSONImages(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.withLabels(ctx, conn, images)
+}
+
+// parseJSONImages parses the one JSON object per line image listings of
+// nerdctl and docker. Their repositories are shortened, so references are
+// normalized to match those in archives.
+func parseJSONImages(s string) ([]RemoteImage, error) {
+	var images []RemoteImage
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var img nerdctlImage
+		if err := json.Unmarshal([]byte(line), &img); err != nil {
+			return nil, fmt.Errorf("unable to parse image list, unexpected line %q: %w", line, err)
+		}
+
+		if img.Repository == "<none>" || img.Tag == "<none>" {
+			continue
+		}
+
+		ref, err := archive.NormalizeReference(img.Repository + ":" + img.Tag)
+		if err != nil {
+			return nil, err
+		}
+
+		ri := RemoteImage{Reference: ref, Size: img.Size}
+		if img.Digest != "<none>" {
+			ri.Digest = img.Digest
+		}
+		if img.Platform != "" {
+			ri.Platforms = []string{img.Platform}
+		}
+
+		images = append(images, ri)
+	}
+
+	return images, nil
+}
+
+func (n *nerdctl) tagCommand(source, target string) string {
+	return n.command(fmt.Sprintf("tag %s %s", remote.Quote(source), remote.Quote(target)))
+}
+
+func (n *nerdctl) Tag(ctx context.Context, conn *remote.Conn, source, target string) error {
+	_, err := run(ctx, conn, n.tagCommand(source, target))
+	return err
+}
+
+func (n *nerdctl) Remove(ctx context.Context, conn *remote.Conn, ref string) error {
+	_, err := run(ctx, conn, n.command("rmi "+remote.Quote(ref)))
+	return err
+}
+
+// normalizeImported normalizes the shortened references printed by nerdctl
+// and docker, leaving any it can't parse as they are.
+func normalizeImported(imported []ImportedImage) []ImportedImage {
+	for i, img := range imported {
+		if ref, err := archive.NormalizeReference(img.Reference); err == nil {
+			imported[i].Reference = ref
+		}
+	}
+
+	return imported
+}
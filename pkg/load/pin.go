@@ -0,0 +1,153 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// PinOptions configures how images are pinned on the Supervisor VMs.
+type PinOptions struct {
+	// Parallel is the maximum number of VMs that are updated concurrently.
+	// Values less than 1 update every VM at once.
+	Parallel int
+	// Unpin removes the pin from the images instead, allowing the kubelet to
+	// garbage collect them again.
+	Unpin bool
+	// Runtime is the name of the container runtime holding the images,
+	// overriding the profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace holding the images, overriding
+	// the profile's runtime config. Defaults to k8s.io.
+	Namespace string
+}
+
+// PinResult holds the outcome of pinning images on a single Supervisor VM.
+type PinResult struct {
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Duration is how long pinning took for the VM.
+	Duration time.Duration `json:"duration"`
+	// References are the references that were pinned or unpinned.
+	References []string `json:"references,omitempty"`
+	// Missing are the references that weren't on the VM.
+	Missing []string `json:"missing,omitempty"`
+	// Err is the error encountered while pinning on the VM, if any.
+	Err error `json:"-"`
+}
+
+// Pin pins, or unpins, the image references on every Supervisor control plane
+// VM. A PinResult is returned for every VM that was attempted; the returned
+// error joins the errors of every failure.
+func Pin(ctx context.Context, refs []string, opts PinOptions) ([]PinResult, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Strs("references", refs).Bool("unpin", opts.Unpin).Msg("beginning pin")
+
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no references to pin")
+	}
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return nil, err
+	}
+
+	runtime, err := newRuntime(c, Options{Runtime: opts.Runtime, Namespace: opts.Namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	ld, vms, err := newLoader(ctx, c, runtime, Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	results := make([]PinResult, len(vms))
+	forEachVM(vms, opts.Parallel, func(i int, vm string) {
+		results[i] = ld.pinVM(ctx, vm, refs, !opts.Unpin)
+	})
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.VM, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// pinVM pins, or unpins, the references present on the VM.
+func (ld *loader) pinVM(ctx context.Context, vm string, refs []string, pinned bool) (r PinResult) {
+	start := time.Now()
+
+	r = PinResult{VM: vm}
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
+
+	conn, err := ld.connect(ctx, vm)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	images, err := ld.listImages(ctx, conn)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	present := map[string]bool{}
+	for _, img := range images {
+		present[img.Reference] = true
+	}
+
+	var existing []string
+	for _, ref := range refs {
+		if present[ref] {
+			existing = append(existing, ref)
+		} else {
+			r.Missing = append(r.Missing, ref)
+		}
+	}
+
+	if err := ld.pin(ctx, conn, existing, pinned); err != nil {
+		r.Err = err
+		return r
+	}
+	r.References = existing
+
+	if len(r.Missing) > 0 {
+		r.Err = fmt.Errorf("%d references not present", len(r.Missing))
+	}
+
+	return r
+}
+
+// pin pins, or unpins, the references on the VM.
+func (ld *loader) pin(ctx context.Context, conn *remote.Conn, refs []string, pinned bool) error {
+	labels := map[string]string{PinnedLabel: ""}
+	if pinned {
+		labels[PinnedLabel] = pinnedValue
+	}
+
+	for _, ref := range refs {
+		zerolog.Ctx(ctx).Debug().Str("address", conn.Server.Host).Str("reference", ref).Bool("pinned", pinned).Msg("pinning image")
+		if err := ld.runtime.Label(ctx, conn, ref, labels); err != nil {
+			return fmt.Errorf("unable to pin %s: %w", ref, err)
+		}
+	}
+
+	return nil
+}
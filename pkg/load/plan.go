@@ -0,0 +1,291 @@
+package load
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// LoadPlan describes what a load would do without doing it.
+type LoadPlan struct {
+	// Profile is the name of the profile the plan was made with.
+	Profile string `json:"profile"`
+	// VCenter is the address of the vCenter server.
+	VCenter string `json:"vcenter"`
+	// Jumpbox is the address of the jumpbox connections go through, if any.
+	Jumpbox string `json:"jumpbox,omitempty"`
+	// ControlPlane is the address of the Supervisor's control plane.
+	ControlPlane string `json:"controlPlane"`
+	// VMs are the addresses of the Supervisor control plane VMs discovered.
+	VMs []string `json:"vms"`
+	// Runtime is the name of the container runtime images would be loaded
+	// into.
+	Runtime string `json:"runtime"`
+	// Namespace is the containerd namespace images would be loaded into.
+	Namespace string `json:"namespace,omitempty"`
+	// Mode is how containers would be transferred to each VM.
+	Mode Mode `json:"mode"`
+	// Strategy is how containers would reach the VMs.
+	Strategy Strategy `json:"strategy"`
+	// Compress is the compression requested for transfers.
+	Compress Compression `json:"compress"`
+	// StagingDir is the directory on the VMs containers would be staged in.
+	StagingDir string `json:"stagingDir"`
+	// Parallel is the maximum number of VMs that would be loaded
+	// concurrently.
+	Parallel int `json:"parallel"`
+	// Atomic is set when the VMs would be rolled back if any VM failed.
+	Atomic bool `json:"atomic,omitempty"`
+	// Pin is set when the imported images would be pinned.
+	Pin bool `json:"pin,omitempty"`
+	// Preflight is the report of the preflight checks of every VM.
+	Preflight []PreflightResult `json:"preflight"`
+	// Containers are the containers that would be loaded, in order.
+	Containers []PlannedContainer `json:"containers"`
+}
+
+// PlannedContainer describes how a single container would be loaded.
+type PlannedContainer struct {
+	// Container is the container as given.
+	Container string `json:"container"`
+	// Size is the size of the container in bytes, or -1 if it was not
+	// inspected: when it would be streamed straight through, or would be
+	// exported from the local Docker daemon or another profile's Supervisor.
+	Size int64 `json:"size"`
+	// Digest is the sha256 digest of the container, if inspected.
+	Digest string `json:"digest,omitempty"`
+	// References are the image references in the container, if inspected.
+	References []string `json:"references,omitempty"`
+	// Tags are the additional references the images would be given.
+	Tags []Tag `json:"tags,omitempty"`
+	// Transfers describe what would happen on each VM.
+	Transfers []PlannedTransfer `json:"transfers"`
+}
+
+// PlanAction is what would be done with a container on a VM.
+type PlanAction string
+
+const (
+	// TransferAction sends the container to the VM and imports it.
+	TransferAction PlanAction = "transfer"
+	// SkipAction leaves the VM alone as it already has every image.
+	SkipAction PlanAction = "skip"
+)
+
+// PlannedTransfer describes what would happen to a container on a single VM.
+type PlannedTransfer struct {
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Action is what would be done on the VM.
+	Action PlanAction `json:"action"`
+	// Method is how the container would reach the VM's container runtime:
+	// stream, staged, delta or relay.
+	Method string `json:"method,omitempty"`
+	// Compression is how the container would be compressed in transit, if
+	// it would be.
+	Compression Compression `json:"compression,omitempty"`
+	// Target is where the container would be staged, if it would be, as a
+	// mktemp template.
+	Target string `json:"target,omitempty"`
+	// Commands are the shell commands that would be run, from the transfer
+	// through to tagging, labelling, pinning and listing the images to
+	// verify them. Commands reading the container from stdin have it
+	// streamed from the local machine, or from the relay VM when relayed.
+	Commands []string `json:"commands,omitempty"`
+	// Err is the error that kept the VM from being checked, if any.
+	Err error `json:"-"`
+}
+
+// Plan resolves the Supervisor and makes the same read-only checks as Load,
+// returning what loading the containers with opts would do without copying or
+// importing anything.
+func Plan(ctx context.Context, containers []string, opts Options) (*LoadPlan, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Msg("beginning plan")
+
+	p, err := prepare(ctx, c, containers, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	defer p.cleanup()
+	opts = p.opts
+
+	ld, vms, err := newLoader(ctx, c, p.runtime, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	plan := &LoadPlan{
+		Profile:      config.CurrentProfile().Name,
+		VCenter:      c.VCenterConfig.SSH.Host,
+		ControlPlane: ld.controlPlane,
+		VMs:          vms,
+		Runtime:      p.runtime.Name(),
+		Mode:         opts.Mode,
+		Strategy:     opts.Strategy,
+		Compress:     opts.Compress,
+		StagingDir:   opts.StagingDir,
+		Parallel:     opts.Parallel,
+		Atomic:       opts.Atomic,
+		Pin:          opts.Pin,
+		Preflight:    ld.preflight(ctx, vms, p.archives),
+		Containers:   make([]PlannedContainer, len(containers)),
+	}
+
+	if c.JumpboxConfig != nil {
+		plan.Jumpbox = c.JumpboxConfig.Host
+	}
+
+	if p.runtime.Name() != DockerRuntime {
+		if plan.Namespace = opts.Namespace; plan.Namespace == "" && c.RuntimeConfig != nil {
+			plan.Namespace = c.RuntimeConfig.Namespace
+		}
+		if plan.Namespace == "" {
+			plan.Namespace = DefaultNamespace
+		}
+	}
+
+	provenances := make([]Provenance, len(containers))
+	for j, container := range containers {
+		pc := PlannedContainer{Container: container, Size: -1, Transfers: make([]PlannedTransfer, len(vms))}
+		if a := p.archives[j]; a != nil {
+			provenances[j] = newProvenance(container, a.Digest)
+			pc.Size, pc.Digest, pc.References = a.Size, a.Digest, a.References()
+			if pc.Tags, err = tagsFor(a, opts); err != nil {
+				return nil, err
+			}
+		}
+		plan.Containers[j] = pc
+	}
+
+	forEachVM(vms, opts.Parallel, func(i int, vm string) {
+		var (
+			images []RemoteImage
+			c      *codec
+		)
+
+		conn, err := ld.connect(ctx, vm)
+		if err == nil {
+			c = ld.codecFor(ctx, conn)
+		}
+		if err == nil && !opts.Force && !p.stream {
+			images, err = ld.listImages(ctx, conn)
+		}
+
+		for j, a := range p.archives {
+			t := ld.planTransfer(i, vms, containers[j], p.stream, c)
+			t.Err = err
+
+			skip := false
+			if err == nil && a != nil && !opts.Force {
+				if alreadyLoaded(expectations(a, nil), images) {
+					t, skip = PlannedTransfer{VM: vm, Action: SkipAction}, true
+				}
+			}
+
+			t.Commands = append(t.Commands, ld.finishCommands(a, plan.Containers[j].Tags, provenances[j], skip)...)
+			plan.Containers[j].Transfers[i] = t
+		}
+	})
+
+	return plan, nil
+}
+
+// planTransfer describes how the container would be transferred to the i'th
+// VM, compressed with the codec if non-nil. stream is set when the container
+// would be streamed straight through without being inspected.
+func (ld *loader) planTransfer(i int, vms []string, container string, stream bool, c *codec) PlannedTransfer {
+	t := PlannedTransfer{VM: vms[i], Action: TransferAction, Method: string(ld.opts.Mode)}
+
+	importCommand := ld.runtime.importCommand()
+	copyCommand := func(target string) string {
+		return "scp -t " + remote.Quote(target)
+	}
+	if c != nil {
+		t.Compression = c.compression
+		importCommand, copyCommand = c.pipeCommand(importCommand), c.writeCommand
+	}
+
+	if stream {
+		t.Method = string(StreamMode)
+		t.Commands = []string{importCommand}
+		return t
+	}
+
+	if ld.opts.Strategy == RelayStrategy && len(vms) > 1 {
+		t.Method, t.Target = string(RelayStrategy), ld.stagingTemplate(container)
+		if i == 0 {
+			t.Commands = []string{"mktemp " + remote.Quote(t.Target), copyCommand(t.Target), ld.runtime.importFileCommand(t.Target)}
+			return t
+		}
+
+		// Peers receive the container uncompressed from the relay VM
+		t.Compression = ""
+
+		user := supervisor.VMClientConfig(ld.config, ld.password).User
+		t.Commands = []string{relayCommand(remote.NewAskpass(ld.password), user, vms[i], ld.runtime.importCommand(), t.Target)}
+		return t
+	}
+
+	switch ld.opts.Mode {
+	case StagedMode:
+		t.Target = ld.stagingTemplate(container)
+		t.Commands = []string{
+			"mktemp " + remote.Quote(t.Target),
+			copyCommand(t.Target),
+			ld.runtime.importFileCommand(t.Target),
+			"rm -f " + remote.Quote(t.Target),
+		}
+	case DeltaMode:
+		if _, ok := ld.runtime.(ContentLister); !ok {
+			t.Method = string(StreamMode)
+		}
+		t.Commands = []string{importCommand}
+	default:
+		t.Commands = []string{importCommand}
+	}
+
+	return t
+}
+
+// finishCommands returns the commands run once the archive's images are on
+// the VM: tagging them, labelling them with their provenance unless the
+// transfer was skipped, pinning them if requested and listing them to verify
+// them. Only the listing is known when the archive is nil.
+func (ld *loader) finishCommands(a *archive.Archive, tags []Tag, p Provenance, skipped bool) []string {
+	if a == nil {
+		return []string{ld.runtime.listCommand()}
+	}
+
+	var cmds []string
+	for _, t := range tags {
+		cmds = append(cmds, ld.runtime.tagCommand(t.Source, t.Target))
+	}
+
+	refs := verifiedReferences(a, tags)
+	if !skipped {
+		for _, ref := range refs {
+			if cmd := ld.runtime.labelCommand(ref, p.labels()); cmd != "" {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+
+	if ld.opts.Pin {
+		for _, ref := range refs {
+			if cmd := ld.runtime.labelCommand(ref, map[string]string{PinnedLabel: pinnedValue}); cmd != "" {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+
+	return append(cmds, ld.runtime.listCommand())
+}
@@ -0,0 +1,78 @@
+package load
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+func TestFinishCommands(t *testing.T) {
+	a, err := archive.InspectReader(bytes.NewReader(dockerSave(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []Tag{{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"}}
+	p := Provenance{Source: "/build/foo.tar", Version: "v1.0.0"}
+
+	tests := []struct {
+		name    string
+		runtime Runtime
+		archive *archive.Archive
+		tags    []Tag
+		pin     bool
+		skipped bool
+		want    []string
+	}{
+		{
+			name:    "ctr",
+			runtime: &ctr{namespace: DefaultNamespace},
+			archive: a,
+			tags:    tags,
+			pin:     true,
+			want: []string{
+				"ctr -n k8s.io images tag --force docker.io/library/foo:dev localhost:5000/vmware/foo:dev",
+				"ctr -n k8s.io images label docker.io/library/foo:dev ultravisor.tvs.github.io/source=/build/foo.tar ultravisor.tvs.github.io/version=v1.0.0",
+				"ctr -n k8s.io images label localhost:5000/vmware/foo:dev ultravisor.tvs.github.io/source=/build/foo.tar ultravisor.tvs.github.io/version=v1.0.0",
+				"ctr -n k8s.io images label docker.io/library/foo:dev io.cri-containerd.pinned=pinned",
+				"ctr -n k8s.io images label localhost:5000/vmware/foo:dev io.cri-containerd.pinned=pinned",
+				"ctr -n k8s.io images ls",
+			},
+		},
+		{
+			name:    "skipped",
+			runtime: &ctr{namespace: DefaultNamespace},
+			archive: a,
+			skipped: true,
+			want:    []string{"ctr -n k8s.io images ls"},
+		},
+		{
+			name:    "not inspected",
+			runtime: &ctr{namespace: DefaultNamespace},
+			pin:     true,
+			want:    []string{"ctr -n k8s.io images ls"},
+		},
+		{
+			name:    "docker",
+			runtime: &dockerCLI{},
+			archive: a,
+			tags:    tags,
+			pin:     true,
+			want: []string{
+				"docker tag docker.io/library/foo:dev localhost:5000/vmware/foo:dev",
+				"docker images --no-trunc --digests --format '{{json .}}'",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ld := &loader{runtime: tt.runtime, opts: Options{Pin: tt.pin}}
+			if got := ld.finishCommands(tt.archive, tt.tags, p, tt.skipped); !slices.Equal(got, tt.want) {
+				t.Errorf("finishCommands() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/util/output"
+)
+
+// PreflightStatus is the outcome of a single preflight check.
+type PreflightStatus string
+
+const (
+	// PassStatus means the check found nothing stopping the load.
+	PassStatus PreflightStatus = "pass"
+	// WarnStatus means the load may proceed but could run into trouble.
+	WarnStatus PreflightStatus = "warn"
+	// FailStatus means the load would fail on the VM.
+	FailStatus PreflightStatus = "fail"
+)
+
+// PreflightCheck is the outcome of checking a single requirement on a VM.
+type PreflightCheck struct {
+	// Name identifies the requirement that was checked.
+	Name string `json:"name"`
+	// Status is the outcome of the check.
+	Status PreflightStatus `json:"status"`
+	// Detail explains the outcome.
+	Detail string `json:"detail,omitempty"`
+}
+
+// PreflightResult holds the checks made against a single Supervisor VM.
+type PreflightResult struct {
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Checks are the outcomes of each requirement checked on the VM.
+	Checks []PreflightCheck `json:"checks,omitempty"`
+	// Err is the error that kept the VM from being checked, if any.
+	Err error `json:"-"`
+}
+
+// Go reports whether the VM is ready to be loaded: it was reachable and no
+// check failed.
+func (r PreflightResult) Go() bool {
+	if r.Err != nil {
+		return false
+	}
+
+	for _, c := range r.Checks {
+		if c.Status == FailStatus {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Go reports whether every VM in the report is ready to be loaded.
+func Go(results []PreflightResult) bool {
+	for _, r := range results {
+		if !r.Go() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PreflightError is returned when a VM fails its preflight checks, so nothing
+// was loaded.
+type PreflightError struct {
+	// Results is the full preflight report.
+	Results []PreflightResult
+}
+
+func (e *PreflightError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if !r.Go() {
+			failed = append(failed, r.VM)
+		}
+	}
+
+	return fmt.Sprintf("preflight failed on %s", strings.Join(failed, ", "))
+}
+
+// preflighter is implemented by runtimes that can check the VM has what they
+// need before anything is loaded.
+type preflighter interface {
+	// preflightChecks returns the requirements of the runtime.
+	preflightChecks() []preflightCheck
+}
+
+// preflightCheck is a requirement checked by running a shell command on the
+// VM, which passes when the command exits successfully.
+type preflightCheck struct {
+	name string
+	cmd  string
+	// failure describes the problem when the command fails.
+	failure string
+}
+
+// containerdSocket is where containerd listens on the Supervisor VMs.
+const containerdSocket = "/run/containerd/containerd.sock"
+
+func binaryCheck(name string) preflightCheck {
+	return preflightCheck{
+		name:    name + " binary",
+		cmd:     "command -v " + name,
+		failure: name + " not found in PATH",
+	}
+}
+
+func (c *ctr) preflightChecks() []preflightCheck {
+	return []preflightCheck{
+		binaryCheck("ctr"),
+		{
+			name:    "containerd socket",
+			cmd:     "test -S " + containerdSocket,
+			failure: containerdSocket + " is not a socket",
+		},
+		{
+			name:    "namespace",
+			cmd:     c.command("namespaces ls -q") + " | grep -qxF " + remote.Quote(c.namespace),
+			failure: fmt.Sprintf("containerd namespace %s does not exist", c.namespace),
+		},
+	}
+}
+
+func (c *crictl) preflightChecks() []preflightCheck {
+	return append(c.ctr.preflightChecks(), binaryCheck("crictl"))
+}
+
+func (n *nerdctl) preflightChecks() []preflightCheck {
+	return append(n.ctr.preflightChecks(), binaryCheck("nerdctl"))
+}
+
+func (d *dockerCLI) preflightChecks() []preflightCheck {
+	return []preflightCheck{
+		binaryCheck("docker"),
+		{
+			name:    "docker socket",
+			cmd:     "test -S /var/run/docker.sock",
+			failure: "/var/run/docker.sock is not a socket",
+		},
+	}
+}
+
+// Preflight checks every Supervisor control plane VM is ready to have the
+// containers loaded with opts, without sending anything to them. A
+// PreflightResult is returned for every VM; a *PreflightError is returned
+// alongside them if any VM is not ready.
+func Preflight(ctx context.Context, containers []string, opts Options) ([]PreflightResult, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Msg("beginning preflight")
+
+	p, err := prepare(ctx, c, containers, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	defer p.cleanup()
+
+	ld, vms, err := newLoader(ctx, c, p.runtime, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	results := ld.preflight(ctx, vms, p.archives)
+	if !Go(results) {
+		return results, &PreflightError{Results: results}
+	}
+
+	return results, nil
+}
+
+// preflight checks every VM. Archives are nil when the container is streamed
+// without being inspected, in which case its size is unknown.
+func (ld *loader) preflight(ctx context.Context, vms []string, archives []*archive.Archive) []PreflightResult {
+	results := make([]PreflightResult, len(vms))
+	forEachVM(vms, ld.opts.Parallel, func(i int, vm string) {
+		results[i] = PreflightResult{VM: vm}
+
+		conn, err := ld.connect(ctx, vm)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+
+		results[i].Checks = ld.preflightVM(ctx, conn, ld.stagingRequired(i, len(vms), archives))
+	})
+
+	return results
+}
+
+// stagingRequired returns the bytes of staging space the i'th VM needs, or -1
+// if unknown. Staged containers are removed once imported so only the largest
+// needs to fit, except on the relay VM which holds every container at once.
+func (ld *loader) stagingRequired(i, vms int, archives []*archive.Archive) int64 {
+	relay := ld.opts.Strategy == RelayStrategy && vms > 1
+	if relay && i > 0 {
+		return 0
+	}
+
+	var required int64
+	for _, a := range archives {
+		if a == nil {
+			return -1
+		}
+
+		if relay {
+			required += a.Size
+		} else {
+			required = max(required, a.Size)
+		}
+	}
+
+	return required
+}
+
+// preflightVM runs the runtime's checks and checks the staging directory can
+// hold required bytes.
+func (ld *loader) preflightVM(ctx context.Context, conn *remote.Conn, required int64) []PreflightCheck {
+	l := zerolog.Ctx(ctx)
+	vm := conn.Server.Host
+
+	var checks []PreflightCheck
+	if p, ok := ld.runtime.(preflighter); ok {
+		for _, pc := range p.preflightChecks() {
+			l.Debug().Str("address", vm).Str("check", pc.name).Msg("running preflight check")
+
+			check := PreflightCheck{Name: pc.name, Status: PassStatus}
+			if _, _, err := conn.Run(ctx, pc.cmd); err != nil {
+				check.Status, check.Detail = FailStatus, pc.failure
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if ld.opts.Compress != NoCompression {
+		checks = append(checks, ld.compressionCheck(ctx, conn))
+	}
+
+	return append(checks, ld.stagingCheck(ctx, conn, required))
+}
+
+// stagingCheck compares the free space in the staging directory with the
+// space required. Only staged loads fail for lack of space; the others merely
+// fall back to staging when streaming is unsupported.
+func (ld *loader) stagingCheck(ctx context.Context, conn *remote.Conn, required int64) PreflightCheck {
+	check := PreflightCheck{Name: "staging space", Status: PassStatus}
+
+	staged := ld.opts.Mode == StagedMode || ld.opts.Strategy == RelayStrategy
+	shortfall := WarnStatus
+	if staged {
+		shortfall = FailStatus
+	}
+
+	stdout, stderr, err := conn.Run(ctx, "df -Pk "+remote.Quote(ld.opts.StagingDir))
+	if err != nil {
+		check.Status = shortfall
+		check.Detail = fmt.Sprintf("unable to check %s: %s", ld.opts.StagingDir, strings.TrimSpace(stderr))
+		return check
+	}
+
+	available, err := parseDfAvailable(stdout)
+	if err != nil {
+		check.Status, check.Detail = shortfall, err.Error()
+		return check
+	}
+
+	switch {
+	case required < 0:
+		check.Status = WarnStatus
+		check.Detail = fmt.Sprintf("%s free in %s, container size unknown", output.Size(available), ld.opts.StagingDir)
+	case available < required:
+		check.Status = shortfall
+		check.Detail = fmt.Sprintf("%s free in %s, %s required", output.Size(available), ld.opts.StagingDir, output.Size(required))
+	default:
+		check.Detail = fmt.Sprintf("%s free in %s", output.Size(available), ld.opts.StagingDir)
+	}
+
+	return check
+}
+
+// parseDfAvailable parses the bytes available from the output of `df -Pk`:
+//
+//	Filesystem 1024-blocks Used Available Capacity Mounted on
+//	/dev/root 10218772 3211900 6466616 34% /
+func parseDfAvailable(s string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unable to parse disk usage %q", s)
+	}
+
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("unable to parse disk usage, unexpected line %q", lines[len(lines)-1])
+	}
+
+	kb, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse available space %q: %w", fields[3], err)
+	}
+
+	return kb * 1024, nil
+}
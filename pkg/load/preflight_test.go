@@ -0,0 +1,60 @@
+package load
+
+import "testing"
+
+func TestParseDfAvailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "available",
+			s: `Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/root         10218772 3211900   6466616      34% /
+`,
+			want: 6466616 * 1024,
+		},
+		{
+			// df -P keeps a long device name on the same line
+			name: "long filesystem",
+			s: `Filesystem                                   1024-blocks    Used Available Capacity Mounted on
+/dev/mapper/vg_supervisor-lv_containerd_data    51475068 1048576  47789052       3% /var/lib/containerd
+`,
+			want: 47789052 * 1024,
+		},
+		{
+			name:    "no filesystem",
+			s:       "df: /tmp/missing: No such file or directory\n",
+			wantErr: true,
+		},
+		{
+			name: "short line",
+			s: `Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/root         10218772
+`,
+			wantErr: true,
+		},
+		{
+			name: "not a number",
+			s: `Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/root         10218772 3211900   6.2G      34% /
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDfAvailable(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDfAvailable() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseDfAvailable() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
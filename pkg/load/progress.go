@@ -0,0 +1,177 @@
+package load
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the transfer of a container to a single VM.
+type Progress struct {
+	// Container is the container being transferred.
+	Container string `json:"container"`
+	// VM is the address of the Supervisor VM receiving the container.
+	VM string `json:"vm"`
+	// Sent is the number of bytes sent so far.
+	Sent int64 `json:"sent"`
+	// Total is the number of bytes that will be sent, or -1 if unknown.
+	Total int64 `json:"total"`
+	// Elapsed is how long the transfer has been running.
+	Elapsed time.Duration `json:"elapsed"`
+	// Done is set on the final snapshot of the transfer, whether or not it
+	// succeeded.
+	Done bool `json:"done,omitempty"`
+}
+
+// Rate returns the average throughput of the transfer in bytes per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(p.Sent) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the time remaining from the average throughput, returning -1
+// when the total is unknown or nothing has been sent yet.
+func (p Progress) ETA() time.Duration {
+	rate := p.Rate()
+	if p.Total < 0 || rate == 0 {
+		return -1
+	}
+
+	return time.Duration(float64(p.Total-p.Sent) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// progressInterval is the minimum time between progress reports of a single
+// transfer.
+const progressInterval = 250 * time.Millisecond
+
+// progressReader counts the bytes read through it, reporting them to the
+// load's progress func at most every progressInterval.
+type progressReader struct {
+	r      io.Reader
+	report func(Progress)
+	start  time.Time
+
+	mu   sync.Mutex
+	p    Progress
+	last time.Time
+}
+
+// track wraps r so the bytes read from it are reported as the progress of
+// the container's transfer to the VM. total is the size of r, or -1 if
+// unknown. The reader must be finished once the transfer completes.
+func (ld *loader) track(r io.Reader, container, vm string, total int64) *progressReader {
+	pr := &progressReader{
+		r:      r,
+		report: ld.opts.Progress,
+		start:  time.Now(),
+		p:      Progress{Container: container, VM: vm, Total: total},
+	}
+
+	if pr.report != nil {
+		pr.report(pr.p)
+	}
+
+	return pr
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.advance(int64(n))
+	return n, err
+}
+
+// advance records n more bytes sent.
+func (pr *progressReader) advance(n int64) {
+	if pr.report == nil {
+		return
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.p.Sent += n
+	if now := time.Now(); now.Sub(pr.last) >= progressInterval {
+		pr.last = now
+		pr.p.Elapsed = now.Sub(pr.start)
+		pr.report(pr.p)
+	}
+}
+
+// finish reports the final progress of the transfer.
+func (pr *progressReader) finish() {
+	if pr.report == nil {
+		return
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.p.Done {
+		return
+	}
+
+	pr.p.Elapsed = time.Since(pr.start)
+	pr.p.Done = true
+	pr.report(pr.p)
+}
+
+// ddProgress parses the standard error of `dd status=progress`, reporting the
+// bytes copied as the progress of a transfer. Anything else written, such as
+// the errors of the commands dd feeds, is kept.
+type ddProgress struct {
+	pr *progressReader
+	// copied is the number of bytes dd last reported copied.
+	copied int64
+	// partial is the unterminated end of the output.
+	partial []byte
+	other   bytes.Buffer
+}
+
+// ddCopied matches the progress lines dd overwrites as it copies and its
+// final summary, e.g. "1048576 bytes (1.0 MB, 1.0 MiB) copied, 1 s, 1.0 MB/s".
+var ddCopied = regexp.MustCompile(`^(\d+) bytes .*copied`)
+
+// ddRecords matches the record counts in dd's final summary.
+var ddRecords = regexp.MustCompile(`^\d+\+\d+ records (in|out)$`)
+
+// Write implements io.Writer. Progress lines are terminated by a carriage
+// return and every other line by a newline.
+func (d *ddProgress) Write(b []byte) (int, error) {
+	d.partial = append(d.partial, b...)
+	for {
+		i := bytes.IndexAny(d.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		d.line(string(d.partial[:i]))
+		d.partial = d.partial[i+1:]
+	}
+
+	return len(b), nil
+}
+
+func (d *ddProgress) line(s string) {
+	if m := ddCopied.FindStringSubmatch(s); m != nil {
+		if n, err := strconv.ParseInt(m[1], 10, 64); err == nil && n > d.copied {
+			d.pr.advance(n - d.copied)
+			d.copied = n
+		}
+		return
+	}
+
+	if s != "" && !ddRecords.MatchString(s) {
+		d.other.WriteString(s + "\n")
+	}
+}
+
+// String returns everything written that wasn't dd's progress.
+func (d *ddProgress) String() string {
+	return d.other.String() + string(d.partial)
+}
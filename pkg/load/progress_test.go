@@ -0,0 +1,43 @@
+package load
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDDProgress(t *testing.T) {
+	// Captured from dd status=progress piping into ssh, whose error follows
+	stderr := "104857600 bytes (105 MB, 100 MiB) copied, 1 s, 105 MB/s\r" +
+		"209715200 bytes (210 MB, 200 MiB) copied, 2 s, 105 MB/s\r" +
+		"286+1 records in\n" +
+		"286+1 records out\n" +
+		"300000000 bytes (300 MB, 286 MiB) copied, 2.86107 s, 105 MB/s\n" +
+		"ctr: content digest sha256:abc: not found\n"
+
+	var sent []int64
+	ld := &loader{opts: Options{Progress: func(p Progress) { sent = append(sent, p.Sent) }}}
+	pr := ld.track(nil, "foo.tar", "10.0.0.12", 300000000)
+	d := &ddProgress{pr: pr}
+
+	// Written in small chunks to split lines across writes
+	for b := []byte(stderr); len(b) > 0; {
+		n := min(len(b), 7)
+		if _, err := d.Write(b[:n]); err != nil {
+			t.Fatal(err)
+		}
+		b = b[n:]
+	}
+	pr.finish()
+
+	if want := int64(300000000); pr.p.Sent != want {
+		t.Errorf("Sent = %d, want %d", pr.p.Sent, want)
+	}
+
+	if !slices.IsSorted(sent) || sent[len(sent)-1] != 300000000 {
+		t.Errorf("reported %v, want increasing to 300000000", sent)
+	}
+
+	if want := "ctr: content digest sha256:abc: not found\n"; d.String() != want {
+		t.Errorf("String() = %q, want %q", d.String(), want)
+	}
+}
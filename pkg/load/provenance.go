@@ -0,0 +1,141 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/version"
+)
+
+// Labels recording the provenance of every image ultravisor loads.
+const (
+	LoadedByLabel     = "ultravisor.tvs.github.io/loaded-by"
+	LoadedFromLabel   = "ultravisor.tvs.github.io/loaded-from"
+	LoadedAtLabel     = "ultravisor.tvs.github.io/loaded-at"
+	SourceLabel       = "ultravisor.tvs.github.io/source"
+	SourceDigestLabel = "ultravisor.tvs.github.io/source-digest"
+	VersionLabel      = "ultravisor.tvs.github.io/version"
+)
+
+// Provenance records who loaded an image, from where and when.
+type Provenance struct {
+	// LoadedBy is the local user that loaded the image.
+	LoadedBy string `json:"loadedBy,omitempty"`
+	// LoadedFrom is the hostname of the machine the image was loaded from.
+	LoadedFrom string `json:"loadedFrom,omitempty"`
+	// LoadedAt is when the image was loaded, in RFC 3339 format.
+	LoadedAt string `json:"loadedAt,omitempty"`
+	// Source is the container the image was loaded from.
+	Source string `json:"source,omitempty"`
+	// SourceDigest is the digest of the container the image was loaded from,
+	// if it was read in full before loading.
+	SourceDigest string `json:"sourceDigest,omitempty"`
+	// Version is the version of ultravisor that loaded the image.
+	Version string `json:"version,omitempty"`
+}
+
+// Provenance returns the provenance recorded on the image, if it was loaded by
+// ultravisor.
+func (img RemoteImage) Provenance() (Provenance, bool) {
+	if _, ok := img.Labels[LoadedAtLabel]; !ok {
+		return Provenance{}, false
+	}
+
+	return Provenance{
+		LoadedBy:     img.Labels[LoadedByLabel],
+		LoadedFrom:   img.Labels[LoadedFromLabel],
+		LoadedAt:     img.Labels[LoadedAtLabel],
+		Source:       img.Labels[SourceLabel],
+		SourceDigest: img.Labels[SourceDigestLabel],
+		Version:      img.Labels[VersionLabel],
+	}, true
+}
+
+// newProvenance describes a load of container happening now from this
+// machine.
+func newProvenance(container, digest string) Provenance {
+	p := Provenance{
+		LoadedBy:     "unknown",
+		LoadedFrom:   "unknown",
+		LoadedAt:     time.Now().UTC().Format(time.RFC3339),
+		Source:       container,
+		SourceDigest: digest,
+		Version:      version.Get().Version,
+	}
+
+	if u, err := user.Current(); err == nil {
+		p.LoadedBy = u.Username
+	} else if u := os.Getenv("USER"); u != "" {
+		p.LoadedBy = u
+	}
+
+	if h, err := os.Hostname(); err == nil {
+		p.LoadedFrom = h
+	}
+
+	if !isSource(container) {
+		if abs, err := filepath.Abs(container); err == nil {
+			p.Source = abs
+		}
+	}
+
+	return p
+}
+
+// labels returns the provenance as image labels. Whitespace and commas are
+// replaced as `ctr images ls` can't list labels containing them.
+func (p Provenance) labels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range map[string]string{
+		LoadedByLabel:     p.LoadedBy,
+		LoadedFromLabel:   p.LoadedFrom,
+		LoadedAtLabel:     p.LoadedAt,
+		SourceLabel:       p.Source,
+		SourceDigestLabel: p.SourceDigest,
+		VersionLabel:      p.Version,
+	} {
+		if v == "" {
+			continue
+		}
+
+		labels[k] = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || r == ',' {
+				return '_'
+			}
+			return r
+		}, v)
+	}
+
+	return labels
+}
+
+// recordProvenance labels the references on the VM with their provenance.
+// Runtimes that can't label images are skipped.
+func (ld *loader) recordProvenance(ctx context.Context, conn *remote.Conn, refs []string, p Provenance) error {
+	l := zerolog.Ctx(ctx)
+
+	labels := p.labels()
+	for _, ref := range refs {
+		err := ld.runtime.Label(ctx, conn, ref, labels)
+		if errors.Is(err, errLabelsUnsupported) {
+			l.Debug().Str("address", conn.Server.Host).Str("runtime", ld.runtime.Name()).Msg("runtime cannot label images, provenance not recorded")
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to record provenance of %s: %w", ref, err)
+		}
+	}
+
+	return nil
+}
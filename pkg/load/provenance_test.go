@@ -0,0 +1,79 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProvenanceLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Provenance
+		want map[string]string
+	}{
+		{
+			name: "every field",
+			p: Provenance{
+				LoadedBy:     "tvs",
+				LoadedFrom:   "workstation",
+				LoadedAt:     "2026-10-18T06:59:26Z",
+				Source:       "/build/foo.tar",
+				SourceDigest: fooDigest,
+				Version:      "v1.0.0",
+			},
+			want: map[string]string{
+				LoadedByLabel:     "tvs",
+				LoadedFromLabel:   "workstation",
+				LoadedAtLabel:     "2026-10-18T06:59:26Z",
+				SourceLabel:       "/build/foo.tar",
+				SourceDigestLabel: fooDigest,
+				VersionLabel:      "v1.0.0",
+			},
+		},
+		{
+			name: "empty fields",
+			p:    Provenance{LoadedAt: "2026-10-18T06:59:26Z", Source: "-"},
+			want: map[string]string{
+				LoadedAtLabel: "2026-10-18T06:59:26Z",
+				SourceLabel:   "-",
+			},
+		},
+		{
+			// ctr images ls separates labels with commas and columns with
+			// whitespace
+			name: "whitespace and commas",
+			p:    Provenance{LoadedBy: "DOMAIN\\Jane Doe", Source: "/build/my images/foo,bar.tar"},
+			want: map[string]string{
+				LoadedByLabel: "DOMAIN\\Jane_Doe",
+				SourceLabel:   "/build/my_images/foo_bar.tar",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.labels(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("labels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteImageProvenance(t *testing.T) {
+	p := Provenance{
+		LoadedBy:   "tvs",
+		LoadedFrom: "workstation",
+		LoadedAt:   "2026-10-18T06:59:26Z",
+		Source:     "docker://foo:dev",
+		Version:    "v1.0.0",
+	}
+
+	got, ok := RemoteImage{Labels: p.labels()}.Provenance()
+	if !ok || got != p {
+		t.Errorf("Provenance() = %+v, %t, want %+v, true", got, ok, p)
+	}
+
+	if _, ok := (RemoteImage{Labels: map[string]string{PinnedLabel: pinnedValue}}).Provenance(); ok {
+		t.Error("Provenance() found provenance on an image ultravisor didn't load")
+	}
+}
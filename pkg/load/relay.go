@@ -0,0 +1,127 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// Strategy determines how containers reach the Supervisor VMs.
+type Strategy string
+
+const (
+	// DirectStrategy uploads the container to every VM.
+	DirectStrategy Strategy = "direct"
+	// RelayStrategy uploads the container to the first VM only, which
+	// forwards it to the other VMs over the management network.
+	RelayStrategy Strategy = "relay"
+)
+
+// Strategies lists the supported strategies.
+var Strategies = []Strategy{DirectStrategy, RelayStrategy}
+
+// relay holds the state of a relayed load: the VM the containers are uploaded
+// to, and the askpass letting it SSH to its peers with the Supervisor root
+// password.
+type relay struct {
+	hub     *remote.Conn
+	askpass *remote.Askpass
+	// staged are the paths of the archives uploaded to the relay VM.
+	staged map[*archive.Archive]string
+}
+
+// startRelay uploads the archives to the first VM and gives it the password to
+// SSH to the others. The relay must be stopped once done, even if starting
+// fails.
+func (ld *loader) startRelay(ctx context.Context, vms, containers []string, archives []*archive.Archive) (*relay, error) {
+	l := zerolog.Ctx(ctx)
+
+	hub, err := ld.connect(ctx, vms[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to relay VM: %w", err)
+	}
+
+	rl := &relay{hub: hub, askpass: remote.NewAskpass(ld.password), staged: map[*archive.Archive]string{}}
+	ld.relay = rl
+
+	for j, a := range archives {
+		l.Debug().Str("address", hub.Server.Host).Str("file", a.Path).Msg("uploading file to relay VM")
+		staged, err := ld.stage(ctx, hub, a.Path)
+		if err != nil {
+			return rl, fmt.Errorf("unable to stage %s on relay VM: %w", a.Path, err)
+		}
+		rl.staged[a] = staged
+		if err := ld.copy(ctx, hub, containers[j], a, staged); err != nil {
+			return rl, fmt.Errorf("unable to upload %s to relay VM: %w", a.Path, err)
+		}
+	}
+
+	// Installed once uploaded so it only lives as long as the relaying
+	if err := rl.askpass.Install(ctx, hub); err != nil {
+		return rl, err
+	}
+
+	return rl, nil
+}
+
+// stop removes the uploaded archives and the askpass, even if ctx has been
+// cancelled.
+func (rl *relay) stop(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	if err := rl.askpass.Uninstall(ctx, rl.hub); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, staged := range rl.staged {
+		if _, stderr, err := rl.hub.Run(ctx, "rm -f "+remote.Quote(staged)); err != nil {
+			errs = append(errs, fmt.Errorf("unable to remove staged file from relay VM: %w: %s", err, strings.TrimSpace(stderr)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// transfer imports the archive uploaded to the relay VM into the VM's container
+// runtime, piping it across from the relay VM for its peers and reporting the
+// progress under name.
+func (rl *relay) transfer(ctx context.Context, ld *loader, conn *remote.Conn, name string, a *archive.Archive) ([]ImportedImage, error) {
+	l := zerolog.Ctx(ctx)
+	vm, staged := conn.Server.Host, rl.staged[a]
+
+	if vm == rl.hub.Server.Host {
+		l.Debug().Str("address", vm).Str("file", staged).Msg("load to container runtime")
+		return ld.runtime.Import(ctx, conn, staged, nil)
+	}
+
+	user := supervisor.VMClientConfig(ld.config, ld.password).User
+	cmd := relayCommand(rl.askpass, user, vm, ld.runtime.importCommand(), staged)
+
+	// The relay VM reports how much it has sent through dd
+	pr := ld.track(nil, name, vm, a.Size)
+	defer pr.finish()
+	stderr := &ddProgress{pr: pr}
+
+	l.Debug().Str("address", vm).Str("relay", rl.hub.Server.Host).Str("file", a.Path).Msg("relaying file to container runtime")
+	stdout, err := rl.hub.RunWithStderr(ctx, cmd, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to relay %s from %s: %w: %s", a.Path, rl.hub.Server.Host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return ld.runtime.parseImport(stdout), nil
+}
+
+// relayCommand returns the command the relay VM runs to import the staged
+// file into the runtime on vm with importCommand, reporting its progress on
+// stderr.
+func relayCommand(askpass *remote.Askpass, user, vm, importCommand, staged string) string {
+	return fmt.Sprintf("set -o pipefail; dd if=%s bs=1M status=progress | %s", remote.Quote(staged), askpass.Command(user, vm, importCommand))
+}
@@ -0,0 +1,114 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// rollbackSuffix is appended to the tag of references preserved for rollback.
+const rollbackSuffix = "ultravisor-rollback"
+
+// rollback records which of the container's references, and their tags,
+// existed on a VM prior to import so the VM can be returned to that state.
+// Existing references are preserved by tagging them with a rollback reference,
+// which also keeps their content from being garbage collected.
+type rollback struct {
+	runtime Runtime
+	conn    *remote.Conn
+	// previous maps every reference to whether it existed before the
+	// import.
+	previous map[string]bool
+}
+
+// prepareRollback preserves the references that already exist in images.
+func prepareRollback(ctx context.Context, runtime Runtime, conn *remote.Conn, refs []string, images []RemoteImage) (*rollback, error) {
+	existing := map[string]bool{}
+	for _, img := range images {
+		existing[img.Reference] = true
+	}
+
+	rb := &rollback{runtime: runtime, conn: conn, previous: map[string]bool{}}
+	for _, ref := range refs {
+		rb.previous[ref] = existing[ref]
+		if !existing[ref] {
+			continue
+		}
+
+		if err := tagImage(ctx, runtime, conn, ref, rollbackReference(ref)); err != nil {
+			return nil, fmt.Errorf("unable to preserve %s for rollback: %w", ref, err)
+		}
+	}
+
+	return rb, nil
+}
+
+// restore returns every reference to its state before the import: previous
+// images are tagged back into place and new references are removed.
+func (rb *rollback) restore(ctx context.Context) error {
+	var errs []error
+	for ref, existed := range rb.previous {
+		if !existed {
+			if err := removeImage(ctx, rb.runtime, rb.conn, ref); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if err := tagImage(ctx, rb.runtime, rb.conn, rollbackReference(ref), ref); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := removeImage(ctx, rb.runtime, rb.conn, rollbackReference(ref)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// commit discards the references preserved for rollback.
+func (rb *rollback) commit(ctx context.Context) error {
+	var errs []error
+	for ref, existed := range rb.previous {
+		if !existed {
+			continue
+		}
+
+		if err := removeImage(ctx, rb.runtime, rb.conn, rollbackReference(ref)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rollbackReference returns the reference used to preserve ref.
+func rollbackReference(ref string) string {
+	name, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name + "-" + rollbackSuffix
+	}
+
+	return name + ":" + rollbackSuffix
+}
+
+func tagImage(ctx context.Context, runtime Runtime, conn *remote.Conn, source, target string) error {
+	if err := runtime.Tag(ctx, conn, source, target); err != nil {
+		return fmt.Errorf("unable to tag %s as %s: %w", source, target, err)
+	}
+
+	return nil
+}
+
+func removeImage(ctx context.Context, runtime Runtime, conn *remote.Conn, ref string) error {
+	if err := runtime.Remove(ctx, conn, ref); err != nil {
+		return fmt.Errorf("unable to remove %s: %w", ref, err)
+	}
+
+	return nil
+}
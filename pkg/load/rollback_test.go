@@ -0,0 +1,31 @@
+package load
+
+import "testing"
+
+func TestRollbackReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "docker.io/library/foo:dev", want: "docker.io/library/foo:dev-ultravisor-rollback"},
+		{ref: "docker.io/library/foo", want: "docker.io/library/foo:ultravisor-rollback"},
+		{ref: "localhost:5000/vmware/bar:1.2.3", want: "localhost:5000/vmware/bar:1.2.3-ultravisor-rollback"},
+		{ref: "localhost:5000/vmware/bar", want: "localhost:5000/vmware/bar:ultravisor-rollback"},
+		{
+			ref:  "docker.io/library/foo:dev@sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+			want: "docker.io/library/foo:dev-ultravisor-rollback",
+		},
+		{
+			ref:  "docker.io/library/foo@sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+			want: "docker.io/library/foo:ultravisor-rollback",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := rollbackReference(tt.ref); got != tt.want {
+				t.Errorf("rollbackReference(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// Runtime is a container runtime on the Supervisor VMs that images are loaded
+// into. Each runtime parses its own command output into structured results.
+type Runtime interface {
+	// Name returns the name the runtime is selected by.
+	Name() string
+	// Import imports an image archive into the runtime. The archive is read
+	// from r when it is non-nil, otherwise from file on the VM.
+	Import(ctx context.Context, conn *remote.Conn, file string, r io.Reader) ([]ImportedImage, error)
+	// List returns the images known to the runtime.
+	List(ctx context.Context, conn *remote.Conn) ([]RemoteImage, error)
+	// Tag gives the image referenced by source the additional reference
+	// target, replacing any image target already refers to.
+	Tag(ctx context.Context, conn *remote.Conn, source, target string) error
+	// Remove removes the image reference.
+	Remove(ctx context.Context, conn *remote.Conn, ref string) error
+	// Label sets labels on the image reference. Labels with an empty value
+	// are removed.
+	Label(ctx context.Context, conn *remote.Conn, ref string, labels map[string]string) error
+	// InUse returns the images of the running containers, each given as a
+	// reference, a name@digest reference or a digest.
+	InUse(ctx context.Context, conn *remote.Conn) ([]string, error)
+
+	pipeline
+	scripted
+}
+
+// ContentLister is implemented by runtimes that can list the blobs in their
+// content store, which is required for delta transfers.
+type ContentLister interface {
+	// ListContent returns the digests of the blobs in the content store.
+	ListContent(ctx context.Context, conn *remote.Conn) (map[string]bool, error)
+}
+
+// pipeline holds a runtime's export and import as shell commands, allowing
+// images to be piped from one VM to another.
+type pipeline interface {
+	// exportCommand returns a command writing an archive of the images to
+	// stdout.
+	exportCommand(refs []string) string
+	// importCommand returns a command importing an archive from stdin.
+	importCommand() string
+	// importFileCommand returns a command importing the archive file on the
+	// VM.
+	importFileCommand(file string) string
+	// parseImport parses the output of the import command.
+	parseImport(stdout string) []ImportedImage
+}
+
+// scripted holds a runtime's tag, label and list operations as shell
+// commands, so a plan can show them.
+type scripted interface {
+	// tagCommand returns a command giving the image referenced by source the
+	// additional reference target.
+	tagCommand(source, target string) string
+	// labelCommand returns a command setting labels on the image reference,
+	// or an empty string if the runtime can't label images.
+	labelCommand(ref string, labels map[string]string) string
+	// listCommand returns a command listing the images known to the
+	// runtime.
+	listCommand() string
+}
+
+// ImportedImage is an image reported by a runtime as imported.
+type ImportedImage struct {
+	// Reference is the name of the image.
+	Reference string `json:"reference"`
+	// Digest is the digest of the image, if reported by the runtime.
+	Digest string `json:"digest,omitempty"`
+}
+
+// RemoteImage is an image known to the container runtime on a Supervisor VM.
+type RemoteImage struct {
+	// Reference is the name of the image.
+	Reference string `json:"reference"`
+	// MediaType is the media type of the image's manifest or index.
+	MediaType string `json:"mediaType,omitempty"`
+	// Digest is the digest of the image's manifest or index. It is empty when
+	// the runtime does not track manifest digests.
+	Digest string `json:"digest,omitempty"`
+	// Size is the human readable size reported by the runtime.
+	Size string `json:"size,omitempty"`
+	// Platforms are the platforms the image supports.
+	Platforms []string `json:"platforms,omitempty"`
+	// Labels are the labels attached to the image.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Pinned is set when the image is pinned, protecting it from the
+	// kubelet's image garbage collection.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// PinnedLabel is the containerd label marking an image as pinned for the CRI,
+// which keeps the kubelet from garbage collecting it.
+const (
+	PinnedLabel = "io.cri-containerd.pinned"
+	pinnedValue = "pinned"
+)
+
+// errLabelsUnsupported indicates the runtime can't label existing images.
+var errLabelsUnsupported = errors.New("runtime does not support labelling images")
+
+// Runtime names.
+const (
+	CtrRuntime     = "ctr"
+	CrictlRuntime  = "crictl"
+	NerdctlRuntime = "nerdctl"
+	DockerRuntime  = "docker"
+)
+
+// Runtimes lists the supported runtimes.
+var Runtimes = []string{CtrRuntime, CrictlRuntime, NerdctlRuntime, DockerRuntime}
+
+// DefaultNamespace is the containerd namespace used by Kubernetes, which is
+// where images must be for the Supervisor to run them.
+const DefaultNamespace = "k8s.io"
+
+// NewRuntime returns the runtime with the given name. An empty name selects
+// ctr and an empty namespace selects DefaultNamespace. The namespace is
+// ignored by docker.
+func NewRuntime(name, namespace string) (Runtime, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	switch name {
+	case "", CtrRuntime:
+		return &ctr{namespace: namespace}, nil
+	case CrictlRuntime:
+		return &crictl{ctr: ctr{namespace: namespace}}, nil
+	case NerdctlRuntime:
+		return &nerdctl{ctr: ctr{namespace: namespace}}, nil
+	case DockerRuntime:
+		return &dockerCLI{}, nil
+	default:
+		return nil, fmt.Errorf("unknown runtime %q, must be one of %v", name, Runtimes)
+	}
+}
+
+// errStreamUnsupported indicates the remote runtime treated "-" as a file name
+// rather than reading the archive from stdin.
+var errStreamUnsupported = errors.New("runtime does not support importing from stdin")
+
+// run executes cmd, wrapping any error with the command's stderr.
+func run(ctx context.Context, conn *remote.Conn, cmd string) (string, error) {
+	stdout, stderr, err := conn.Run(ctx, cmd)
+	if err != nil {
+		return stdout, commandError(cmd, err, stderr)
+	}
+
+	return stdout, nil
+}
+
+// runImport executes the import command cmd, streaming r to it if non-nil.
+func runImport(ctx context.Context, conn *remote.Conn, cmd string, r io.Reader) (string, error) {
+	var (
+		stdout, stderr string
+		err            error
+	)
+
+	if r != nil {
+		stdout, stderr, err = conn.RunWithInput(ctx, cmd, r)
+	} else {
+		stdout, stderr, err = conn.Run(ctx, cmd)
+	}
+
+	if err != nil {
+		if r != nil && strings.Contains(stderr, "open -:") {
+			return stdout, errStreamUnsupported
+		}
+		return stdout, commandError(cmd, err, stderr)
+	}
+
+	return stdout, nil
+}
+
+func commandError(cmd string, err error, stderr string) error {
+	name, _, _ := strings.Cut(cmd, " ")
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%s failed: %w: %s", name, err, msg)
+	}
+
+	return fmt.Errorf("%s failed: %w", name, err)
+}
+
+// quoteAll quotes each argument and joins them for use in a command.
+func quoteAll(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = remote.Quote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// parseLoaded parses the "Loaded image: <ref>" lines printed by docker and
+// nerdctl when loading an archive.
+func parseLoaded(s string) []ImportedImage {
+	var imported []ImportedImage
+	for _, line := range strings.Split(s, "\n") {
+		if ref, ok := strings.CutPrefix(strings.TrimSpace(line), "Loaded image: "); ok {
+			imported = append(imported, ImportedImage{Reference: ref})
+		}
+	}
+
+	return slices.Clip(imported)
+}
@@ -0,0 +1,93 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// SaveOptions configures how images are saved from a Supervisor VM.
+type SaveOptions struct {
+	// VM is the address of the VM images are exported from. Defaults to the
+	// first control plane VM.
+	VM string
+	// Runtime is the name of the container runtime to export from,
+	// overriding the profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace to export from, overriding the
+	// profile's runtime config. Defaults to k8s.io.
+	Namespace string
+}
+
+// Save exports the image references from a Supervisor control plane VM,
+// streaming the archive produced by the container runtime to w. The address of
+// the VM the images were exported from is returned.
+func Save(ctx context.Context, refs []string, w io.Writer, opts SaveOptions) (string, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Strs("references", refs).Msg("beginning save")
+
+	if len(refs) == 0 {
+		return "", fmt.Errorf("no references to save")
+	}
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return "", err
+	}
+
+	runtime, err := newRuntime(c, Options{Runtime: opts.Runtime, Namespace: opts.Namespace})
+	if err != nil {
+		return "", err
+	}
+
+	ld, vms, err := newLoader(ctx, c, runtime, Options{})
+	if err != nil {
+		return "", err
+	}
+	defer ld.close(ctx)
+
+	vm := opts.VM
+	if vm == "" {
+		vm = vms[0]
+	} else if !slices.Contains(vms, vm) {
+		return "", fmt.Errorf("%s is not a Supervisor control plane VM, must be one of %v", vm, vms)
+	}
+
+	conn, err := ld.connect(ctx, vm)
+	if err != nil {
+		return vm, err
+	}
+
+	// Check the references up front, as a failed export leaves a partial
+	// archive behind
+	images, err := ld.listImages(ctx, conn)
+	if err != nil {
+		return vm, err
+	}
+
+	var missing []string
+	for _, ref := range refs {
+		if !slices.ContainsFunc(images, func(img RemoteImage) bool { return img.Reference == ref }) {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		return vm, fmt.Errorf("references not present on %s: %s", vm, strings.Join(missing, ", "))
+	}
+
+	l.Debug().Str("address", vm).Strs("references", refs).Msg("exporting images")
+	if stderr, err := conn.RunWithOutput(ctx, runtime.exportCommand(refs), w); err != nil {
+		return vm, fmt.Errorf("unable to export images from %s: %w: %s", vm, err, strings.TrimSpace(stderr))
+	}
+
+	return vm, nil
+}
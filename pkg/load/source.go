@@ -0,0 +1,203 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/config/configmanager"
+	"github.com/tvs/ultravisor/pkg/docker"
+)
+
+// Stdin is the container name used to read a container from standard input.
+const Stdin = "-"
+
+const (
+	// dockerPrefix marks a container exported from the local Docker daemon,
+	// e.g. docker://myctrl:dev.
+	dockerPrefix = "docker://"
+	// ociPrefix marks a container read from an OCI image layout directory,
+	// e.g. oci:./build/layout:tag.
+	ociPrefix = "oci:"
+	// profilePrefix marks a container exported from the Supervisor of
+	// another profile, e.g. profile:lab/localhost:5000/vmware/foo:1.2.3.
+	// Several references may be given, separated by commas.
+	profilePrefix = "profile:"
+)
+
+// isSource reports whether container is read as a stream, rather than being a
+// local archive file: stdin, an image in the local Docker daemon, an OCI
+// layout directory, or images on another profile's Supervisor.
+func isSource(container string) bool {
+	return container == Stdin ||
+		strings.HasPrefix(container, dockerPrefix) ||
+		strings.HasPrefix(container, ociPrefix) ||
+		strings.HasPrefix(container, profilePrefix)
+}
+
+// ProfileSource returns the container name for images exported from the
+// Supervisor of the named profile.
+func ProfileSource(profile string, refs []string) string {
+	return profilePrefix + profile + "/" + strings.Join(refs, ",")
+}
+
+// openSource returns a reader producing the archive for a source container.
+// The caller must close the reader.
+func openSource(ctx context.Context, container string, stdin io.Reader) (io.ReadCloser, error) {
+	switch {
+	case container == Stdin:
+		if stdin == nil {
+			return nil, fmt.Errorf("no stdin to load the container from")
+		}
+		return io.NopCloser(stdin), nil
+	case strings.HasPrefix(container, dockerPrefix):
+		c, err := docker.NewClient("")
+		if err != nil {
+			return nil, err
+		}
+		return c.Save(ctx, strings.TrimPrefix(container, dockerPrefix))
+	case strings.HasPrefix(container, ociPrefix):
+		dir, tag := parseOCILayout(strings.TrimPrefix(container, ociPrefix))
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("unable to find OCI layout: %w", err)
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(archive.WriteLayoutDir(pw, dir, tag))
+		}()
+		return pr, nil
+	case strings.HasPrefix(container, profilePrefix):
+		name, refs, err := parseProfileSource(strings.TrimPrefix(container, profilePrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if name == config.CurrentProfile().Name {
+			return nil, fmt.Errorf("images can't be copied from profile %s to itself", name)
+		}
+
+		c, err := configmanager.LoadProfile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// The export runs against the other profile's Supervisor, reached
+		// through its own jumpbox and vCenter
+		pr, pw := io.Pipe()
+		go func() {
+			vm, err := Save(c.WithContext(ctx), refs, pw, SaveOptions{})
+			if err != nil {
+				err = fmt.Errorf("unable to export from profile %s VM %s: %w", name, vm, err)
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	default:
+		return nil, fmt.Errorf("%s is not a container source", container)
+	}
+}
+
+// parseProfileSource splits a profile source of the form name/ref[,ref...],
+// normalizing the references.
+func parseProfileSource(s string) (name string, refs []string, err error) {
+	name, list, ok := strings.Cut(s, "/")
+	if !ok || name == "" || list == "" {
+		return "", nil, fmt.Errorf("invalid profile source %q, must be profile:<name>/<reference>[,<reference>...]", profilePrefix+s)
+	}
+
+	for _, r := range strings.Split(list, ",") {
+		ref, err := archive.NormalizeReference(r)
+		if err != nil {
+			return "", nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	return name, refs, nil
+}
+
+// parseOCILayout splits an OCI layout reference of the form dir[:tag].
+func parseOCILayout(s string) (dir, tag string) {
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		return s[:i], s[i+1:]
+	}
+
+	return s, ""
+}
+
+func countStdin(containers []string) int {
+	n := 0
+	for _, c := range containers {
+		if c == Stdin {
+			n++
+		}
+	}
+
+	return n
+}
+
+// streamsSource reports whether a source container can be streamed straight
+// through to the VMs. Anything that needs to read the container more than
+// once, or before it is transferred, requires it to be spilled to disk first.
+// Tags are checked against the container's references before anything is
+// transferred, so they require it to be spilled too.
+func streamsSource(containers []string, opts Options) bool {
+	return len(containers) == 1 && isSource(containers[0]) && opts.Mode == StreamMode && !opts.Atomic && opts.Strategy != RelayStrategy &&
+		len(opts.Tags) == 0 && opts.As == ""
+}
+
+// spill copies a source container to a temporary file so it may be read more
+// than once. The caller is responsible for removing the file.
+func spill(ctx context.Context, container string, stdin io.Reader) (string, error) {
+	l := zerolog.Ctx(ctx)
+
+	r, err := openSource(ctx, container, stdin)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "ultravisor-*.tar")
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer f.Close()
+
+	l.Debug().Str("container", container).Str("file", f.Name()).Msg("spilling container to temporary file")
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("unable to read %s: %w", container, err)
+	}
+
+	return f.Name(), nil
+}
+
+// inspectSource inspects a source container as it is read, without keeping a
+// copy. Containers exported from the local Docker daemon or another profile's
+// Supervisor are costly to produce, so they are not inspected and nil is
+// returned.
+func inspectSource(ctx context.Context, container string, stdin io.Reader) (*archive.Archive, error) {
+	if strings.HasPrefix(container, dockerPrefix) || strings.HasPrefix(container, profilePrefix) {
+		return nil, nil
+	}
+
+	r, err := openSource(ctx, container, stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	a, err := archive.InspectReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect container %s: %w", container, err)
+	}
+
+	return a, nil
+}
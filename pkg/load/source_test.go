@@ -0,0 +1,372 @@
+package load
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+// Fixture contents shared by every container layout.
+const (
+	testLayer  = "layer-one\n"
+	testConfig = `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["sha256:x"]}}`
+)
+
+func hexDigest(b string) string {
+	h := sha256.Sum256([]byte(b))
+	return hex.EncodeToString(h[:])
+}
+
+// testManifest is the OCI manifest of the fixture image.
+var testManifest = fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+	`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:%s","size":%d},`+
+	`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:%s","size":%d}]}`,
+	hexDigest(testConfig), len(testConfig), hexDigest(testLayer), len(testLayer))
+
+// dockerSave returns the fixture image as written by docker save, tagged foo:dev.
+func dockerSave(t *testing.T) []byte {
+	t.Helper()
+
+	cfg, layer := hexDigest(testConfig), hexDigest(testLayer)
+	files := []struct{ name, data string }{
+		{"manifest.json", fmt.Sprintf(`[{"Config":"%s.json","RepoTags":["foo:dev"],"Layers":["%s/layer.tar"]}]`, cfg, layer)},
+		{cfg + ".json", testConfig},
+		{layer + "/layer.tar", testLayer},
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Size: int64(len(f.data)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// layoutDir writes the fixture image to an OCI layout directory named foo,
+// tagged with the bare ref name dev as buildkit does.
+func layoutDir(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "foo")
+	index := fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:%s","size":%d,"annotations":{"org.opencontainers.image.ref.name":"dev"}}]}`,
+		hexDigest(testManifest), len(testManifest))
+
+	files := map[string]string{
+		"oci-layout": `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json": index,
+	}
+	for _, blob := range []string{testManifest, testConfig, testLayer} {
+		files[filepath.Join("blobs", "sha256", hexDigest(blob))] = blob
+	}
+
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// fakeDocker serves the Docker Engine API's image export on a unix socket,
+// pointing DOCKER_HOST at it for the duration of the test.
+func fakeDocker(t *testing.T, images map[string][]byte) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/get" {
+			http.NotFound(w, r)
+			return
+		}
+
+		names := r.URL.Query()["names"]
+		b, ok := images[strings.Join(names, ",")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"reference does not exist: %s"}`, strings.Join(names, ","))
+			return
+		}
+
+		_, _ = w.Write(b)
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+
+	t.Setenv("DOCKER_HOST", "unix://"+socket)
+}
+
+func TestIsSource(t *testing.T) {
+	tests := []struct {
+		container string
+		want      bool
+	}{
+		{container: Stdin, want: true},
+		{container: "docker://foo:dev", want: true},
+		{container: "oci:./build/layout", want: true},
+		{container: "oci:./build/layout:dev", want: true},
+		{container: "profile:lab/foo:dev", want: true},
+		{container: "container.tar", want: false},
+		{container: "./build/layout", want: false},
+		{container: "/tmp/containers", want: false},
+		{container: "docker:foo", want: false},
+		{container: "--", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.container, func(t *testing.T) {
+			if got := isSource(tt.container); got != tt.want {
+				t.Errorf("isSource(%q) = %t, want %t", tt.container, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOCILayout(t *testing.T) {
+	tests := []struct {
+		s   string
+		dir string
+		tag string
+	}{
+		{s: "./build/layout", dir: "./build/layout"},
+		{s: "./build/layout:dev", dir: "./build/layout", tag: "dev"},
+		{s: "/tmp/host:5000/layout", dir: "/tmp/host:5000/layout"},
+		{s: "layout:v1.2.3", dir: "layout", tag: "v1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			dir, tag := parseOCILayout(tt.s)
+			if dir != tt.dir || tag != tt.tag {
+				t.Errorf("parseOCILayout(%q) = %q, %q, want %q, %q", tt.s, dir, tag, tt.dir, tt.tag)
+			}
+		})
+	}
+}
+
+func TestParseProfileSource(t *testing.T) {
+	tests := []struct {
+		s       string
+		name    string
+		refs    []string
+		wantErr bool
+	}{
+		{s: "lab/foo:dev", name: "lab", refs: []string{"docker.io/library/foo:dev"}},
+		{s: "lab/foo:dev,localhost:5000/vmware/bar:1.2.3", name: "lab", refs: []string{"docker.io/library/foo:dev", "localhost:5000/vmware/bar:1.2.3"}},
+		{s: "lab", wantErr: true},
+		{s: "/foo:dev", wantErr: true},
+		{s: "lab/", wantErr: true},
+		{s: "lab/Foo", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			name, refs, err := parseProfileSource(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProfileSource(%q) error = %v, wantErr %t", tt.s, err, tt.wantErr)
+			}
+
+			if name != tt.name || !slices.Equal(refs, tt.refs) {
+				t.Errorf("parseProfileSource(%q) = %q, %v, want %q, %v", tt.s, name, refs, tt.name, tt.refs)
+			}
+		})
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	saved := dockerSave(t)
+	fakeDocker(t, map[string][]byte{"foo:dev": saved})
+	layout := layoutDir(t)
+
+	tests := []struct {
+		name      string
+		container string
+		stdin     io.Reader
+		refs      []string
+		wantErr   string
+	}{
+		{
+			name:      "stdin",
+			container: Stdin,
+			stdin:     bytes.NewReader(saved),
+			refs:      []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:      "no stdin",
+			container: Stdin,
+			wantErr:   "no stdin",
+		},
+		{
+			name:      "docker",
+			container: "docker://foo:dev",
+			refs:      []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:      "docker missing image",
+			container: "docker://bar:dev",
+			wantErr:   "reference does not exist: bar:dev",
+		},
+		{
+			name:      "layout",
+			container: "oci:" + layout,
+			refs:      []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:      "layout tag",
+			container: "oci:" + layout + ":dev",
+			refs:      []string{"docker.io/library/foo:dev"},
+		},
+		{
+			name:      "layout missing tag",
+			container: "oci:" + layout + ":latest",
+			wantErr:   `no image tagged "latest"`,
+		},
+		{
+			name:      "layout missing",
+			container: "oci:" + filepath.Join(t.TempDir(), "missing"),
+			wantErr:   "unable to find OCI layout",
+		},
+		{
+			name:      "file",
+			container: "container.tar",
+			wantErr:   "not a container source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := openSource(context.Background(), tt.container, tt.stdin)
+			if err == nil {
+				defer r.Close()
+
+				var a *archive.Archive
+				if a, err = archive.InspectReader(r); err == nil && !slices.Equal(a.References(), tt.refs) {
+					t.Errorf("References() = %v, want %v", a.References(), tt.refs)
+				}
+			}
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("openSource(%q) error = %v", tt.container, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("openSource(%q) error = %v, want %q", tt.container, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpill(t *testing.T) {
+	fakeDocker(t, map[string][]byte{"foo:dev": dockerSave(t)})
+
+	f, err := spill(context.Background(), "docker://foo:dev", nil)
+	if err != nil {
+		t.Fatalf("spill() error = %v", err)
+	}
+	defer os.Remove(f)
+
+	a, err := archive.Inspect(f)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+
+	if want := []string{"docker.io/library/foo:dev"}; !slices.Equal(a.References(), want) {
+		t.Errorf("References() = %v, want %v", a.References(), want)
+	}
+}
+
+func TestInspectSource(t *testing.T) {
+	// Nothing listens here, so exporting from docker would fail
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "docker.sock"))
+	layout := layoutDir(t)
+
+	tests := []struct {
+		name      string
+		container string
+		stdin     io.Reader
+		refs      []string
+		inspected bool
+	}{
+		{name: "stdin", container: Stdin, stdin: bytes.NewReader(dockerSave(t)), refs: []string{"docker.io/library/foo:dev"}, inspected: true},
+		{name: "layout", container: "oci:" + layout + ":dev", refs: []string{"docker.io/library/foo:dev"}, inspected: true},
+		{name: "docker", container: "docker://foo:dev"},
+		{name: "profile", container: "profile:lab/foo:dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := inspectSource(context.Background(), tt.container, tt.stdin)
+			if err != nil {
+				t.Fatalf("inspectSource(%q) error = %v", tt.container, err)
+			}
+
+			if (a != nil) != tt.inspected {
+				t.Fatalf("inspectSource(%q) = %v, want inspected %t", tt.container, a, tt.inspected)
+			}
+
+			if a != nil && !slices.Equal(a.References(), tt.refs) {
+				t.Errorf("References() = %v, want %v", a.References(), tt.refs)
+			}
+		})
+	}
+}
+
+func TestStreamsSource(t *testing.T) {
+	tags := []Tag{{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"}}
+
+	tests := []struct {
+		name       string
+		containers []string
+		opts       Options
+		want       bool
+	}{
+		{name: "stdin", containers: []string{Stdin}, opts: Options{Mode: StreamMode}, want: true},
+		{name: "docker", containers: []string{"docker://foo:dev"}, opts: Options{Mode: StreamMode}, want: true},
+		{name: "file", containers: []string{"foo.tar"}, opts: Options{Mode: StreamMode}},
+		{name: "several sources", containers: []string{Stdin, "docker://foo:dev"}, opts: Options{Mode: StreamMode}},
+		{name: "staged", containers: []string{Stdin}, opts: Options{Mode: StagedMode}},
+		{name: "atomic", containers: []string{Stdin}, opts: Options{Mode: StreamMode, Atomic: true}},
+		{name: "relay", containers: []string{Stdin}, opts: Options{Mode: StreamMode, Strategy: RelayStrategy}},
+		{name: "tags", containers: []string{Stdin}, opts: Options{Mode: StreamMode, Tags: tags}},
+		{name: "as", containers: []string{"docker://foo:dev"}, opts: Options{Mode: StreamMode, As: "localhost:5000/vmware/foo:dev"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamsSource(tt.containers, tt.opts); got != tt.want {
+				t.Errorf("streamsSource(%v) = %t, want %t", tt.containers, got, tt.want)
+			}
+		})
+	}
+}
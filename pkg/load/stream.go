@@ -0,0 +1,194 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// loadStream tees r to the container runtime of every VM at once, inspecting
+// it along the way so each VM can be verified once the stream completes.
+// Nothing is known about the container until it has been read, so VMs that
+// already have its images can't be skipped.
+func (ld *loader) loadStream(ctx context.Context, vms []string, container string, r io.Reader) []Result {
+	l := zerolog.Ctx(ctx)
+	start := time.Now()
+
+	results := make([]Result, len(vms))
+	conns := make([]*remote.Conn, len(vms))
+
+	var t tee
+	for i, vm := range vms {
+		results[i] = Result{Container: container, VM: vm}
+
+		conn, err := ld.connect(ctx, vm)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		conns[i] = conn
+	}
+
+	readers := make([]*io.PipeReader, len(vms))
+	for i := range vms {
+		if conns[i] != nil {
+			readers[i] = t.add()
+		}
+	}
+	inspection := t.add()
+
+	var (
+		g       errgroup.Group
+		a       *archive.Archive
+		inspErr error
+	)
+
+	g.Go(func() error {
+		a, inspErr = archive.InspectReader(inspection)
+		// Keep draining so the VMs receive the whole stream regardless
+		_, _ = io.Copy(io.Discard, inspection)
+		inspection.CloseWithError(inspErr)
+		return nil
+	})
+
+	for i, conn := range conns {
+		if conn == nil {
+			continue
+		}
+
+		i, conn := i, conn
+		g.Go(func() error {
+			l.Debug().Str("address", conn.Server.Host).Str("file", container).Msg("streaming container to container runtime")
+			// Nothing is known about the container until it has been read
+			tracked := ld.track(readers[i], container, conn.Server.Host, -1)
+			imported, err := ld.importStream(ctx, conn, tracked)
+			tracked.finish()
+			readers[i].CloseWithError(err)
+			if err != nil {
+				l.Error().Err(err).Str("address", conn.Server.Host).Msg("unable to stream container")
+				results[i].Err = err
+			}
+			results[i].Imported = imported
+			return nil
+		})
+	}
+
+	copyErr := t.copy(r)
+	_ = g.Wait()
+
+	var p Provenance
+	if a != nil {
+		p = newProvenance(container, a.Digest)
+	}
+
+	for i, conn := range conns {
+		res := &results[i]
+		switch {
+		case res.Err != nil:
+		case copyErr != nil:
+			res.Err = fmt.Errorf("unable to read container: %w", copyErr)
+		case inspErr != nil:
+			res.Err = fmt.Errorf("unable to inspect container: %w", inspErr)
+		default:
+			tags, err := tagsFor(a, ld.opts)
+			if err != nil {
+				res.Err = err
+				break
+			}
+
+			if err := applyTags(ctx, ld.runtime, conn, tags); err != nil {
+				res.Err = err
+				break
+			}
+
+			if err := ld.recordProvenance(ctx, conn, verifiedReferences(a, tags), p); err != nil {
+				res.Err = err
+				break
+			}
+
+			if ld.opts.Pin {
+				if err := ld.pin(ctx, conn, verifiedReferences(a, tags), true); err != nil {
+					res.Err = err
+					break
+				}
+			}
+
+			images, err := ld.listImages(ctx, conn)
+			if err != nil {
+				res.Err = err
+				break
+			}
+			res.Verifications, res.Err = verify(expectations(a, tags), images)
+		}
+		res.Duration = time.Since(start)
+	}
+
+	return results
+}
+
+// tee copies a single reader to several pipes. Unlike io.MultiWriter, a pipe
+// whose reader has gone away is dropped rather than stopping every other pipe.
+type tee struct {
+	mu      sync.Mutex
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+// add returns the reading side of a new pipe fed by the tee.
+func (t *tee) add() *io.PipeReader {
+	pr, pw := io.Pipe()
+	t.writers = append(t.writers, pw)
+	t.failed = append(t.failed, false)
+	return pr
+}
+
+// copy feeds r to every pipe until r is exhausted or every pipe has failed,
+// then closes the pipes.
+func (t *tee) copy(r io.Reader) error {
+	_, err := io.Copy(t, r)
+	if errors.Is(err, errTeeFailed) {
+		err = nil
+	}
+
+	for _, w := range t.writers {
+		w.CloseWithError(err)
+	}
+
+	return err
+}
+
+var errTeeFailed = errors.New("every reader has failed")
+
+// Write implements io.Writer, succeeding as long as one pipe accepts p.
+func (t *tee) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	live := false
+	for i, w := range t.writers {
+		if t.failed[i] {
+			continue
+		}
+
+		if _, err := w.Write(p); err != nil {
+			t.failed[i] = true
+			continue
+		}
+		live = true
+	}
+
+	if !live {
+		return 0, errTeeFailed
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,88 @@
+package load
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTee(t *testing.T) {
+	data := strings.Repeat("ultravisor", 64*1024)
+
+	tests := []struct {
+		name    string
+		readers int
+		// closed are the readers that go away before reading anything
+		closed  []int
+		src     io.Reader
+		wantErr error
+	}{
+		{name: "one reader", readers: 1, src: strings.NewReader(data)},
+		{name: "readers", readers: 3, src: strings.NewReader(data)},
+		{name: "reader goes away", readers: 3, closed: []int{1}, src: strings.NewReader(data)},
+		{name: "every reader goes away", readers: 2, closed: []int{0, 1}, src: strings.NewReader(data)},
+		{
+			name:    "source fails",
+			readers: 2,
+			src:     io.MultiReader(strings.NewReader(data), iotest.ErrReader(errSource)),
+			wantErr: errSource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tee tee
+			readers := make([]*io.PipeReader, tt.readers)
+			for i := range readers {
+				readers[i] = tee.add()
+			}
+
+			closed := map[int]bool{}
+			for _, i := range tt.closed {
+				closed[i] = true
+				readers[i].Close()
+			}
+
+			var wg sync.WaitGroup
+			got := make([]bytes.Buffer, tt.readers)
+			errs := make([]error, tt.readers)
+			for i, r := range readers {
+				if closed[i] {
+					continue
+				}
+
+				i, r := i, r
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = io.Copy(&got[i], r)
+				}()
+			}
+
+			if err := tee.copy(tt.src); !errors.Is(err, tt.wantErr) {
+				t.Errorf("copy() error = %v, want %v", err, tt.wantErr)
+			}
+			wg.Wait()
+
+			for i := range readers {
+				if closed[i] {
+					continue
+				}
+
+				if !errors.Is(errs[i], tt.wantErr) {
+					t.Errorf("reader %d error = %v, want %v", i, errs[i], tt.wantErr)
+				}
+
+				if tt.wantErr == nil && got[i].String() != data {
+					t.Errorf("reader %d read %d bytes, want %d", i, got[i].Len(), len(data))
+				}
+			}
+		})
+	}
+}
+
+var errSource = errors.New("source failed")
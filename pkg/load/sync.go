@@ -0,0 +1,286 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/remote"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// SyncOptions configures how images are reconciled between the Supervisor
+// VMs.
+type SyncOptions struct {
+	// Parallel is the maximum number of VMs that are synced concurrently.
+	// Values less than 1 sync every VM at once.
+	Parallel int
+	// Source is the address of the VM images are copied from. Defaults to the
+	// VM with the most images. Naming the source makes its images
+	// authoritative, overwriting conflicting references on the other VMs.
+	Source string
+	// Force overwrites references that point at a different image on the
+	// target than on the source. Without it, or an explicit Source, such
+	// conflicts are reported and left alone.
+	Force bool
+	// References limits the sync to these image references. Defaults to every
+	// image on the source VM.
+	References []string
+	// Runtime is the name of the container runtime to sync, overriding the
+	// profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace to sync, overriding the profile's
+	// runtime config. Defaults to k8s.io.
+	Namespace string
+}
+
+// SyncResult holds the outcome of syncing images to a single Supervisor VM.
+type SyncResult struct {
+	// VM is the address of the Supervisor VM images were copied to.
+	VM string `json:"vm"`
+	// Source is the address of the Supervisor VM images were copied from.
+	Source string `json:"source"`
+	// Duration is how long the sync took for the VM.
+	Duration time.Duration `json:"duration"`
+	// Verifications are the results of checking each copied reference.
+	Verifications []Verification `json:"verifications,omitempty"`
+	// Conflicts are the references pointing at a different image on the VM
+	// than on the source, which were left alone.
+	Conflicts []Verification `json:"conflicts,omitempty"`
+	// Err is the error encountered while syncing to the VM, if any.
+	Err error `json:"-"`
+}
+
+// Sync copies the images on a source VM to every other Supervisor control plane
+// VM missing them. References pointing at a different image on a VM are
+// reported as conflicts and only overwritten when the source is named or
+// forced. Images are piped directly from the source VM to the others
+// over the management network, which the source SSHes across with the
+// Supervisor root password, as a relay does. A SyncResult is returned for every VM other than the source; the
+// returned error joins the errors of every failure.
+func Sync(ctx context.Context, opts SyncOptions) ([]SyncResult, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Msg("beginning sync")
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return nil, err
+	}
+
+	runtime, err := newRuntime(c, Options{Runtime: opts.Runtime, Namespace: opts.Namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	ld, vms, err := newLoader(ctx, c, runtime, Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	listed := make([]VMImages, len(vms))
+	forEachVM(vms, opts.Parallel, func(i int, vm string) {
+		listed[i] = VMImages{VM: vm}
+
+		conn, err := ld.connect(ctx, vm)
+		if err != nil {
+			listed[i].Err = err
+			return
+		}
+
+		listed[i].Images, listed[i].Err = ld.listImages(ctx, conn)
+	})
+
+	source, err := syncSource(listed, opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	l.Info().Str("address", source.VM).Msg("syncing images from source VM")
+
+	var results []SyncResult
+	for _, vm := range listed {
+		if vm.VM == source.VM {
+			continue
+		}
+
+		r := SyncResult{VM: vm.VM, Source: source.VM, Err: vm.Err}
+		if r.Err == nil {
+			overwrite := opts.Force || opts.Source != ""
+			r.Verifications, r.Conflicts = missingImages(source, vm, opts.References, overwrite)
+		}
+		results = append(results, r)
+	}
+
+	if slices.ContainsFunc(results, func(r SyncResult) bool { return len(r.Verifications) > 0 }) {
+		ld.syncAll(ctx, source.VM, results, opts.Parallel)
+	}
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.VM, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// syncSource picks the VM to sync from: the named VM if any, otherwise the VM
+// with the most images in the inventory.
+func syncSource(listed []VMImages, name string) (VMImages, error) {
+	var source VMImages
+	for _, vm := range listed {
+		if name != "" && vm.VM != name {
+			continue
+		}
+
+		if vm.Err != nil {
+			if name != "" {
+				return source, fmt.Errorf("unable to list source VM %s: %w", name, vm.Err)
+			}
+			continue
+		}
+
+		if source.VM == "" || len(vm.Images) > len(source.Images) {
+			source = vm
+		}
+	}
+
+	if source.VM == "" {
+		if name != "" {
+			return source, fmt.Errorf("source VM %s is not a Supervisor control plane VM", name)
+		}
+		return source, fmt.Errorf("no VM could be listed to sync from")
+	}
+
+	return source, nil
+}
+
+// missingImages returns the references on the source VM that are missing from
+// the target, with the digests expected once copied, and the references the
+// target has with a different digest. References are compared one by one, so
+// a reference is copied even if the target has its image under another name.
+// Conflicting references are only returned as missing when overwrite is set.
+// refs limits the references considered, if set.
+func missingImages(source, target VMImages, refs []string, overwrite bool) (missing, conflicts []Verification) {
+	existing := map[string]string{}
+	for _, img := range target.Images {
+		existing[img.Reference] = img.Digest
+	}
+
+	for _, img := range source.Images {
+		if len(refs) > 0 && !slices.Contains(refs, img.Reference) {
+			continue
+		}
+
+		v := Verification{Reference: img.Reference, Expected: img.Digest}
+		actual, ok := existing[img.Reference]
+		switch {
+		case ok && actual == img.Digest:
+		case ok && !overwrite:
+			v.Actual = actual
+			conflicts = append(conflicts, v)
+		default:
+			missing = append(missing, v)
+		}
+	}
+
+	byReference := func(a, b Verification) int {
+		return strings.Compare(a.Reference, b.Reference)
+	}
+	slices.SortFunc(missing, byReference)
+	slices.SortFunc(conflicts, byReference)
+
+	return missing, conflicts
+}
+
+// syncAll gives the source VM the password to SSH to the targets and pipes
+// the missing images across. The askpass is removed afterwards, even if ctx
+// has been cancelled.
+func (ld *loader) syncAll(ctx context.Context, source string, results []SyncResult, parallel int) {
+	l := zerolog.Ctx(ctx)
+
+	fail := func(err error) {
+		for i := range results {
+			if results[i].Err == nil && len(results[i].Verifications) > 0 {
+				results[i].Err = err
+			}
+		}
+	}
+
+	srcConn, err := ld.connect(ctx, source)
+	if err != nil {
+		fail(fmt.Errorf("unable to connect to source VM: %w", err))
+		return
+	}
+
+	askpass := remote.NewAskpass(ld.password)
+	if err := askpass.Install(ctx, srcConn); err != nil {
+		fail(err)
+		return
+	}
+
+	defer func() {
+		if err := askpass.Uninstall(context.WithoutCancel(ctx), srcConn); err != nil {
+			l.Error().Err(err).Str("address", source).Msg("unable to remove askpass from source VM")
+		}
+	}()
+
+	vms := make([]string, len(results))
+	for i, r := range results {
+		vms[i] = r.VM
+	}
+
+	forEachVM(vms, parallel, func(i int, vm string) {
+		r := &results[i]
+		if r.Err != nil || len(r.Verifications) == 0 {
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			r.Duration = time.Since(start)
+		}()
+
+		r.Verifications, r.Err = ld.syncVM(ctx, askpass, srcConn, vm, r.Verifications)
+	})
+}
+
+// syncVM pipes the expected references from the source VM to the target and
+// verifies them once imported.
+func (ld *loader) syncVM(ctx context.Context, askpass *remote.Askpass, srcConn *remote.Conn, vm string, expected []Verification) ([]Verification, error) {
+	l := zerolog.Ctx(ctx)
+
+	conn, err := ld.connect(ctx, vm)
+	if err != nil {
+		return expected, err
+	}
+
+	refs := make([]string, len(expected))
+	for i, v := range expected {
+		refs[i] = v.Reference
+	}
+
+	user := supervisor.VMClientConfig(ld.config, ld.password).User
+	cmd := fmt.Sprintf("set -o pipefail; %s | %s", ld.runtime.exportCommand(refs), askpass.Command(user, vm, ld.runtime.importCommand()))
+
+	l.Debug().Str("address", vm).Str("source", srcConn.Server.Host).Strs("references", refs).Msg("piping images between VMs")
+	if _, stderr, err := srcConn.RunWithInput(ctx, cmd, nil); err != nil {
+		return expected, fmt.Errorf("unable to copy images from %s: %w: %s", srcConn.Server.Host, err, strings.TrimSpace(stderr))
+	}
+
+	images, err := ld.listImages(ctx, conn)
+	if err != nil {
+		return expected, err
+	}
+
+	return verify(expected, images)
+}
@@ -0,0 +1,111 @@
+package load
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSyncSource(t *testing.T) {
+	listed := []VMImages{
+		{VM: "10.0.0.1", Images: []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest}}},
+		{VM: "10.0.0.2", Images: []RemoteImage{
+			{Reference: "docker.io/library/foo:dev", Digest: fooDigest},
+			{Reference: "docker.io/library/bar:dev", Digest: barDigest},
+		}},
+		{VM: "10.0.0.3", Err: errors.New("unable to connect")},
+	}
+
+	tests := []struct {
+		name    string
+		listed  []VMImages
+		source  string
+		want    string
+		wantErr string
+	}{
+		{name: "most images", listed: listed, want: "10.0.0.2"},
+		{name: "named", listed: listed, source: "10.0.0.1", want: "10.0.0.1"},
+		{name: "named unlisted", listed: listed, source: "10.0.0.3", wantErr: "unable to list source VM 10.0.0.3"},
+		{name: "named unknown", listed: listed, source: "10.0.0.4", wantErr: "not a Supervisor control plane VM"},
+		{name: "nothing listed", listed: listed[2:], wantErr: "no VM could be listed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := syncSource(tt.listed, tt.source)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("syncSource() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("syncSource() error = %v", err)
+			}
+
+			if got.VM != tt.want {
+				t.Errorf("syncSource() = %s, want %s", got.VM, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingImages(t *testing.T) {
+	const otherDigest = "sha256:0123456789abcdef"
+
+	source := VMImages{VM: "10.0.0.1", Images: []RemoteImage{
+		{Reference: "docker.io/library/foo:dev", Digest: fooDigest},
+		{Reference: "docker.io/library/foo:latest", Digest: fooDigest},
+		{Reference: "docker.io/library/bar:dev", Digest: barDigest},
+	}}
+	target := VMImages{VM: "10.0.0.2", Images: []RemoteImage{
+		{Reference: "docker.io/library/foo:dev", Digest: fooDigest},
+		{Reference: "docker.io/library/bar:dev", Digest: otherDigest},
+	}}
+
+	tests := []struct {
+		name          string
+		refs          []string
+		overwrite     bool
+		wantMissing   []Verification
+		wantConflicts []Verification
+	}{
+		{
+			name:          "conflicts left alone",
+			wantMissing:   []Verification{{Reference: "docker.io/library/foo:latest", Expected: fooDigest}},
+			wantConflicts: []Verification{{Reference: "docker.io/library/bar:dev", Expected: barDigest, Actual: otherDigest}},
+		},
+		{
+			name:      "conflicts overwritten",
+			overwrite: true,
+			wantMissing: []Verification{
+				{Reference: "docker.io/library/bar:dev", Expected: barDigest},
+				{Reference: "docker.io/library/foo:latest", Expected: fooDigest},
+			},
+		},
+		{
+			name:        "limited to references",
+			refs:        []string{"docker.io/library/foo:latest"},
+			wantMissing: []Verification{{Reference: "docker.io/library/foo:latest", Expected: fooDigest}},
+		},
+		{
+			name: "references present",
+			refs: []string{"docker.io/library/foo:dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, conflicts := missingImages(source, target, tt.refs, tt.overwrite)
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("missingImages() missing =\n%+v\nwant\n%+v", missing, tt.wantMissing)
+			}
+
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("missingImages() conflicts =\n%+v\nwant\n%+v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/remote"
+)
+
+// Tag is an additional reference given to an image once it has been imported,
+// e.g. so a locally built image replaces one a Supervisor component already
+// refers to.
+type Tag struct {
+	// Source is a reference of an image in a container.
+	Source string `json:"source"`
+	// Target is the reference the image is tagged with.
+	Target string `json:"target"`
+}
+
+// ParseTag parses a tag of the form source=target, normalizing both
+// references.
+func ParseTag(s string) (Tag, error) {
+	source, target, ok := strings.Cut(s, "=")
+	if !ok {
+		return Tag{}, fmt.Errorf("tag %q must be of the form source=target", s)
+	}
+
+	return NewTag(source, target)
+}
+
+// NewTag returns a Tag with both references normalized.
+func NewTag(source, target string) (Tag, error) {
+	var err error
+	t := Tag{}
+
+	if t.Source, err = archive.NormalizeReference(source); err != nil {
+		return Tag{}, fmt.Errorf("invalid tag source: %w", err)
+	}
+
+	if t.Target, err = archive.NormalizeReference(target); err != nil {
+		return Tag{}, fmt.Errorf("invalid tag target: %w", err)
+	}
+
+	return t, nil
+}
+
+// tagsFor returns the tags that apply to the archive: those whose source is
+// one of its references, plus the As target for its only reference.
+func tagsFor(a *archive.Archive, opts Options) ([]Tag, error) {
+	refs := a.References()
+
+	var matched []Tag
+	for _, t := range opts.Tags {
+		if slices.Contains(refs, t.Source) {
+			matched = append(matched, t)
+		}
+	}
+
+	if opts.As != "" {
+		if len(refs) != 1 {
+			return nil, fmt.Errorf("a single target may only be given for one image reference, found %d", len(refs))
+		}
+
+		t, err := NewTag(refs[0], opts.As)
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, t)
+	}
+
+	return matched, nil
+}
+
+// checkTags ensures every tag's source is found in one of the archives, so a
+// typo isn't silently ignored, and that a single target is only given for a
+// single reference.
+func checkTags(archives []*archive.Archive, opts Options) error {
+	if opts.As != "" {
+		var refs []string
+		for _, a := range archives {
+			refs = append(refs, a.References()...)
+		}
+
+		if len(refs) != 1 {
+			return fmt.Errorf("a single target may only be given for one image reference, found %d", len(refs))
+		}
+	}
+
+	for _, t := range opts.Tags {
+		found := false
+		for _, a := range archives {
+			if slices.Contains(a.References(), t.Source) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("tag source %s is not a reference in any container", t.Source)
+		}
+	}
+
+	return nil
+}
+
+// applyTags tags the imported images on the VM.
+func applyTags(ctx context.Context, runtime Runtime, conn *remote.Conn, tags []Tag) error {
+	for _, t := range tags {
+		if err := tagImage(ctx, runtime, conn, t.Source, t.Target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
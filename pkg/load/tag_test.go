@@ -0,0 +1,161 @@
+package load
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Tag
+		wantErr string
+	}{
+		{
+			s:    "foo:dev=localhost:5000/vmware/foo:dev",
+			want: Tag{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"},
+		},
+		{
+			s:    "docker.io/library/foo=foo:1.2.3",
+			want: Tag{Source: "docker.io/library/foo:latest", Target: "docker.io/library/foo:1.2.3"},
+		},
+		{s: "foo:dev", wantErr: "must be of the form source=target"},
+		{s: "Foo=foo:dev", wantErr: "invalid tag source"},
+		{s: "foo:dev=", wantErr: "invalid tag target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseTag(tt.s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseTag(%q) error = %v, want %q", tt.s, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseTag(%q) error = %v", tt.s, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseTag(%q) = %+v, want %+v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsFor(t *testing.T) {
+	foo := &archive.Archive{Images: []archive.Image{{References: []string{"docker.io/library/foo:dev"}, Digest: fooDigest}}}
+	both := &archive.Archive{Images: []archive.Image{
+		{References: []string{"docker.io/library/foo:dev"}, Digest: fooDigest},
+		{References: []string{"docker.io/library/bar:dev"}, Digest: barDigest},
+	}}
+
+	fooTag := Tag{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"}
+	barTag := Tag{Source: "docker.io/library/bar:dev", Target: "localhost:5000/vmware/bar:dev"}
+
+	tests := []struct {
+		name    string
+		archive *archive.Archive
+		opts    Options
+		want    []Tag
+		wantErr bool
+	}{
+		{
+			name:    "matching tags",
+			archive: both,
+			opts:    Options{Tags: []Tag{fooTag, barTag}},
+			want:    []Tag{fooTag, barTag},
+		},
+		{
+			name:    "tags for other archives",
+			archive: foo,
+			opts:    Options{Tags: []Tag{fooTag, barTag}},
+			want:    []Tag{fooTag},
+		},
+		{
+			name:    "as",
+			archive: foo,
+			opts:    Options{As: "localhost:5000/vmware/foo:dev"},
+			want:    []Tag{fooTag},
+		},
+		{
+			name:    "as with several references",
+			archive: both,
+			opts:    Options{As: "localhost:5000/vmware/foo:dev"},
+			wantErr: true,
+		},
+		{
+			name:    "no tags",
+			archive: foo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tagsFor(tt.archive, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tagsFor() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagsFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	foo := &archive.Archive{Images: []archive.Image{{References: []string{"docker.io/library/foo:dev"}, Digest: fooDigest}}}
+	bar := &archive.Archive{Images: []archive.Image{{References: []string{"docker.io/library/bar:dev"}, Digest: barDigest}}}
+
+	tests := []struct {
+		name     string
+		archives []*archive.Archive
+		opts     Options
+		wantErr  string
+	}{
+		{
+			name:     "tags across archives",
+			archives: []*archive.Archive{foo, bar},
+			opts: Options{Tags: []Tag{
+				{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"},
+				{Source: "docker.io/library/bar:dev", Target: "localhost:5000/vmware/bar:dev"},
+			}},
+		},
+		{
+			name:     "unknown source",
+			archives: []*archive.Archive{foo},
+			opts:     Options{Tags: []Tag{{Source: "docker.io/library/bar:dev", Target: "localhost:5000/vmware/bar:dev"}}},
+			wantErr:  "tag source docker.io/library/bar:dev is not a reference in any container",
+		},
+		{
+			name:     "as",
+			archives: []*archive.Archive{foo},
+			opts:     Options{As: "localhost:5000/vmware/foo:dev"},
+		},
+		{
+			name:     "as across archives",
+			archives: []*archive.Archive{foo, bar},
+			opts:     Options{As: "localhost:5000/vmware/foo:dev"},
+			wantErr:  "found 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTags(tt.archives, tt.opts)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("checkTags() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("checkTags() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/supervisor"
+)
+
+// UnloadOptions configures how images are removed from the Supervisor VMs.
+type UnloadOptions struct {
+	// Parallel is the maximum number of VMs that are unloaded concurrently.
+	// Values less than 1 unload every VM at once.
+	Parallel int
+	// Force removes images even if they are used by running containers.
+	Force bool
+	// Runtime is the name of the container runtime images are removed from,
+	// overriding the profile's runtime config. Defaults to ctr.
+	Runtime string
+	// Namespace is the containerd namespace images are removed from,
+	// overriding the profile's runtime config. Defaults to k8s.io.
+	Namespace string
+}
+
+// RemovalStatus is the outcome of removing a single reference from a VM.
+type RemovalStatus string
+
+const (
+	// RemovedStatus indicates the reference was removed.
+	RemovedStatus RemovalStatus = "removed"
+	// AbsentStatus indicates the reference wasn't on the VM.
+	AbsentStatus RemovalStatus = "absent"
+	// InUseStatus indicates the reference was kept because a running
+	// container uses it.
+	InUseStatus RemovalStatus = "in use"
+	// FailedStatus indicates the container runtime failed to remove the
+	// reference.
+	FailedStatus RemovalStatus = "failed"
+)
+
+// Removal is the outcome of removing a single reference from a VM.
+type Removal struct {
+	// Reference is the image reference that was removed.
+	Reference string `json:"reference"`
+	// Status is the outcome of the removal.
+	Status RemovalStatus `json:"status"`
+}
+
+// UnloadResult holds the outcome of removing images from a single Supervisor
+// VM.
+type UnloadResult struct {
+	// VM is the address of the Supervisor VM.
+	VM string `json:"vm"`
+	// Duration is how long the removal took for the VM.
+	Duration time.Duration `json:"duration"`
+	// Removals are the outcomes of removing each reference.
+	Removals []Removal `json:"removals,omitempty"`
+	// Err is the error encountered while unloading the VM, if any.
+	Err error `json:"-"`
+}
+
+// Unload removes the image references from every Supervisor control plane VM.
+// References used by running containers are kept unless opts.Force is set. An
+// UnloadResult is returned for every VM that was attempted; the returned error
+// joins the errors of every failure.
+func Unload(ctx context.Context, refs []string, opts UnloadOptions) ([]UnloadResult, error) {
+	l := zerolog.Ctx(ctx)
+	c := config.Ctx(ctx)
+
+	l.Debug().Interface("config", c).Strs("references", refs).Msg("beginning unload")
+
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no references to unload")
+	}
+
+	if err := supervisor.ValidateConfig(c); err != nil {
+		l.Error().Err(err).Any("config", c).Msg("invalid config")
+		return nil, err
+	}
+
+	runtime, err := newRuntime(c, Options{Runtime: opts.Runtime, Namespace: opts.Namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	ld, vms, err := newLoader(ctx, c, runtime, Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer ld.close(ctx)
+
+	results := make([]UnloadResult, len(vms))
+	forEachVM(vms, opts.Parallel, func(i int, vm string) {
+		results[i] = ld.unloadVM(ctx, vm, refs, opts.Force)
+	})
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.VM, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// unloadVM removes the references from the VM, sharing a single SSH
+// connection between every step.
+func (ld *loader) unloadVM(ctx context.Context, vm string, refs []string, force bool) (r UnloadResult) {
+	l := zerolog.Ctx(ctx)
+	start := time.Now()
+
+	r = UnloadResult{VM: vm}
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
+
+	conn, err := ld.connect(ctx, vm)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	images, err := ld.listImages(ctx, conn)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	digests := map[string]string{}
+	for _, img := range images {
+		digests[img.Reference] = img.Digest
+	}
+
+	var used map[string]bool
+	if !force {
+		inUse, err := ld.runtime.InUse(ctx, conn)
+		if err != nil {
+			r.Err = fmt.Errorf("unable to list running containers: %w", err)
+			return r
+		}
+		used = usedImages(inUse)
+	}
+
+	var errs []error
+	var kept int
+	for _, ref := range refs {
+		d, ok := digests[ref]
+		switch {
+		case !ok:
+			r.Removals = append(r.Removals, Removal{Reference: ref, Status: AbsentStatus})
+		case inUse(used, ref, d):
+			l.Warn().Str("address", vm).Str("reference", ref).Msg("image is used by a running container, keeping it")
+			kept++
+			r.Removals = append(r.Removals, Removal{Reference: ref, Status: InUseStatus})
+		default:
+			l.Debug().Str("address", vm).Str("reference", ref).Msg("removing image")
+			if err := removeImage(ctx, ld.runtime, conn, ref); err != nil {
+				errs = append(errs, err)
+				r.Removals = append(r.Removals, Removal{Reference: ref, Status: FailedStatus})
+				continue
+			}
+			r.Removals = append(r.Removals, Removal{Reference: ref, Status: RemovedStatus})
+		}
+	}
+
+	if kept > 0 {
+		errs = append(errs, fmt.Errorf("%d references kept as they are used by running containers", kept))
+	}
+
+	r.Err = errors.Join(errs...)
+	return r
+}
+
+// usedImages normalizes the images reported as in use by a runtime. Digests
+// are kept as they are.
+func usedImages(images []string) map[string]bool {
+	used := map[string]bool{}
+	for _, img := range images {
+		if strings.HasPrefix(img, "sha256:") {
+			used[img] = true
+			continue
+		}
+
+		if ref, err := archive.NormalizeReference(img); err == nil {
+			img = ref
+		}
+		used[img] = true
+	}
+
+	return used
+}
+
+// inUse reports whether the reference, whose image has the given digest, is
+// used by a running container, either by name or by digest.
+func inUse(used map[string]bool, ref, digest string) bool {
+	if used[ref] {
+		return true
+	}
+
+	if digest == "" {
+		return false
+	}
+
+	name, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+
+	return used[digest] || used[name+"@"+digest]
+}
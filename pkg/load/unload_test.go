@@ -0,0 +1,54 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUsedImages(t *testing.T) {
+	got := usedImages([]string{
+		"foo:dev",
+		"localhost:5000/vmware/bar:1.2.3",
+		fooDigest,
+		"docker.io/library/baz@" + barDigest,
+		"Not A Reference",
+	})
+
+	want := map[string]bool{
+		"docker.io/library/foo:dev":          true,
+		"localhost:5000/vmware/bar:1.2.3":    true,
+		fooDigest:                            true,
+		"docker.io/library/baz@" + barDigest: true,
+		"Not A Reference":                    true,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("usedImages() = %v, want %v", got, want)
+	}
+}
+
+func TestInUse(t *testing.T) {
+	used := usedImages([]string{"foo:dev", fooDigest, "docker.io/library/baz@" + barDigest})
+
+	tests := []struct {
+		name   string
+		ref    string
+		digest string
+		want   bool
+	}{
+		{name: "by name", ref: "docker.io/library/foo:dev", want: true},
+		{name: "by digest", ref: "localhost:5000/vmware/foo:dev", digest: fooDigest, want: true},
+		{name: "by name and digest", ref: "docker.io/library/baz:1.2.3", digest: barDigest, want: true},
+		{name: "other repository with digest", ref: "docker.io/library/qux:1.2.3", digest: barDigest},
+		{name: "unused", ref: "docker.io/library/foo:latest"},
+		{name: "unknown digest", ref: "docker.io/library/foo:latest", digest: "sha256:0123456789ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inUse(used, tt.ref, tt.digest); got != tt.want {
+				t.Errorf("inUse(%q, %q) = %t, want %t", tt.ref, tt.digest, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package load
+
+import (
+	"fmt"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+// Verification is the outcome of checking a single image reference on a
+// Supervisor VM after it has been loaded.
+type Verification struct {
+	// Reference is the image reference that was checked.
+	Reference string `json:"reference"`
+	// Expected is the digest of the image in the archive.
+	Expected string `json:"expected"`
+	// Actual is the digest the VM's container runtime reports for the
+	// reference, or empty if the reference is missing.
+	Actual string `json:"actual,omitempty"`
+	// Present is set when the reference exists on the VM but the runtime
+	// doesn't report its digest, so only its presence could be checked.
+	Present bool `json:"present,omitempty"`
+	// Pinned is set when the reference is pinned on the VM.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// OK reports whether the reference was present with the expected digest, or
+// simply present when the runtime doesn't report digests.
+func (v Verification) OK() bool {
+	return v.Actual == v.Expected || v.Present
+}
+
+// Status describes the verification outcome: ok, present, missing or
+// mismatch.
+func (v Verification) Status() string {
+	switch {
+	case v.Present:
+		return "present"
+	case v.OK():
+		return "ok"
+	case v.Actual == "":
+		return "missing"
+	default:
+		return "mismatch"
+	}
+}
+
+// expectations returns the references expected on a VM once the archive is
+// loaded and tagged, with their expected digests. Images without references
+// can't be looked up and are not verified.
+func expectations(a *archive.Archive, tags []Tag) []Verification {
+	var expected []Verification
+	digests := map[string]string{}
+	for _, img := range a.Images {
+		for _, ref := range img.References {
+			digests[ref] = img.Digest
+			expected = append(expected, Verification{Reference: ref, Expected: img.Digest})
+		}
+	}
+
+	for _, t := range tags {
+		if d, ok := digests[t.Source]; ok {
+			expected = append(expected, Verification{Reference: t.Target, Expected: d})
+		}
+	}
+
+	return expected
+}
+
+// verifiedReferences returns the references expected on a VM once the archive
+// is loaded and tagged.
+func verifiedReferences(a *archive.Archive, tags []Tag) []string {
+	var refs []string
+	for _, v := range expectations(a, tags) {
+		refs = append(refs, v.Reference)
+	}
+
+	return refs
+}
+
+// alreadyLoaded reports whether every expected reference is in images with
+// the expected digest, so loading the archive again can be skipped. Unlike
+// verify, a reference the runtime reports without a digest doesn't count: it
+// may be a different image pushed under the same tag, so it is always loaded.
+func alreadyLoaded(expected []Verification, images []RemoteImage) bool {
+	if len(expected) == 0 {
+		return false
+	}
+
+	digests := map[string]string{}
+	for _, img := range images {
+		digests[img.Reference] = img.Digest
+	}
+
+	for _, v := range expected {
+		if d := digests[v.Reference]; d == "" || d != v.Expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verify checks that every expected reference is present in images with the
+// expected digest.
+func verify(expected []Verification, images []RemoteImage) ([]Verification, error) {
+	digests := map[string]string{}
+	present := map[string]bool{}
+	pinned := map[string]bool{}
+	for _, img := range images {
+		digests[img.Reference] = img.Digest
+		present[img.Reference] = true
+		pinned[img.Reference] = img.Pinned
+	}
+
+	var (
+		verifications []Verification
+		mismatches    int
+	)
+	for _, v := range expected {
+		v.Actual = digests[v.Reference]
+		v.Present = v.Actual == "" && present[v.Reference]
+		v.Pinned = pinned[v.Reference]
+		if !v.OK() {
+			mismatches++
+		}
+
+		verifications = append(verifications, v)
+	}
+
+	if mismatches > 0 {
+		return verifications, fmt.Errorf("verification failed, %d of %d references missing or mismatched", mismatches, len(verifications))
+	}
+
+	return verifications, nil
+}
@@ -0,0 +1,176 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tvs/ultravisor/pkg/archive"
+)
+
+const (
+	fooDigest = "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270"
+	barDigest = "sha256:0e3bbf5b4ca5ed5af8a5e1d5cd3a4b0a6ce0a9d2e09e6ab0e3f13f8cfb7ee1e1"
+)
+
+func TestExpectations(t *testing.T) {
+	a := &archive.Archive{Images: []archive.Image{
+		{References: []string{"docker.io/library/foo:dev", "docker.io/library/foo:latest"}, Digest: fooDigest},
+		{Digest: barDigest},
+	}}
+
+	tests := []struct {
+		name string
+		tags []Tag
+		want []Verification
+	}{
+		{
+			name: "references",
+			want: []Verification{
+				{Reference: "docker.io/library/foo:dev", Expected: fooDigest},
+				{Reference: "docker.io/library/foo:latest", Expected: fooDigest},
+			},
+		},
+		{
+			name: "tags",
+			tags: []Tag{
+				{Source: "docker.io/library/foo:dev", Target: "localhost:5000/vmware/foo:dev"},
+				{Source: "docker.io/library/baz:dev", Target: "localhost:5000/vmware/baz:dev"},
+			},
+			want: []Verification{
+				{Reference: "docker.io/library/foo:dev", Expected: fooDigest},
+				{Reference: "docker.io/library/foo:latest", Expected: fooDigest},
+				{Reference: "localhost:5000/vmware/foo:dev", Expected: fooDigest},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expectations(a, tt.tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expectations() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	expected := []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest}}
+
+	tests := []struct {
+		name       string
+		images     []RemoteImage
+		want       []Verification
+		wantStatus string
+		wantErr    bool
+	}{
+		{
+			name:       "matching digest",
+			images:     []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest, Pinned: true}},
+			want:       []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest, Actual: fooDigest, Pinned: true}},
+			wantStatus: "ok",
+		},
+		{
+			name:       "mismatched digest",
+			images:     []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: barDigest}},
+			want:       []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest, Actual: barDigest}},
+			wantStatus: "mismatch",
+			wantErr:    true,
+		},
+		{
+			name:       "missing tag",
+			images:     []RemoteImage{{Reference: "docker.io/library/foo:latest", Digest: fooDigest}},
+			want:       []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest}},
+			wantStatus: "missing",
+			wantErr:    true,
+		},
+		{
+			// Runtimes that don't track manifest digests can only confirm
+			// the reference was imported
+			name:       "empty actual digest",
+			images:     []RemoteImage{{Reference: "docker.io/library/foo:dev"}},
+			want:       []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest, Present: true}},
+			wantStatus: "present",
+		},
+		{
+			name:       "no images",
+			want:       []Verification{{Reference: "docker.io/library/foo:dev", Expected: fooDigest}},
+			wantStatus: "missing",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verify(expected, tt.images)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("verify() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+
+			if status := got[0].Status(); status != tt.wantStatus {
+				t.Errorf("Status() = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAlreadyLoaded(t *testing.T) {
+	expected := []Verification{
+		{Reference: "docker.io/library/foo:dev", Expected: fooDigest},
+		{Reference: "docker.io/library/foo:latest", Expected: fooDigest},
+	}
+
+	tests := []struct {
+		name     string
+		expected []Verification
+		images   []RemoteImage
+		want     bool
+	}{
+		{
+			name:     "matching digests",
+			expected: expected,
+			images: []RemoteImage{
+				{Reference: "docker.io/library/foo:dev", Digest: fooDigest},
+				{Reference: "docker.io/library/foo:latest", Digest: fooDigest},
+			},
+			want: true,
+		},
+		{
+			name:     "mismatched digest",
+			expected: expected,
+			images: []RemoteImage{
+				{Reference: "docker.io/library/foo:dev", Digest: fooDigest},
+				{Reference: "docker.io/library/foo:latest", Digest: barDigest},
+			},
+		},
+		{
+			name:     "missing tag",
+			expected: expected,
+			images:   []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest}},
+		},
+		{
+			// A rebuilt image pushed under the same tag can't be told apart
+			name:     "empty actual digest",
+			expected: expected,
+			images: []RemoteImage{
+				{Reference: "docker.io/library/foo:dev"},
+				{Reference: "docker.io/library/foo:latest"},
+			},
+		},
+		{
+			name:   "nothing expected",
+			images: []RemoteImage{{Reference: "docker.io/library/foo:dev", Digest: fooDigest}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alreadyLoaded(tt.expected, tt.images); got != tt.want {
+				t.Errorf("alreadyLoaded() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
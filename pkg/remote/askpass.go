@@ -0,0 +1,71 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AskpassLifetime is how long an Askpass program is left on a server before
+// it removes itself, in case it is never uninstalled. It is long enough to
+// outlast the largest operations.
+const AskpassLifetime = 6 * time.Hour
+
+// AskpassTemplate stands in for the path of an Askpass program that has not
+// been installed, matching the names mktemp gives.
+const AskpassTemplate = "/tmp/tmp.XXXXXXXXXX"
+
+// Askpass lets one server SSH to others with a password for the duration of
+// an operation. The password is supplied to ssh by an askpass program that is
+// only readable by the connection's user, so it never appears on a command
+// line.
+type Askpass struct {
+	password string
+	// path is where the program was installed, once installed.
+	path string
+}
+
+// NewAskpass returns an Askpass supplying password.
+func NewAskpass(password string) *Askpass {
+	return &Askpass{password: password}
+}
+
+// Install writes the askpass program to a new temporary file on the server.
+func (a *Askpass) Install(ctx context.Context, conn *Conn) error {
+	program := "#!/bin/sh\nprintf '%s\\n' " + Quote(a.password) + "\n"
+	cmd := fmt.Sprintf(`umask 077 && f=$(mktemp) && cat > "$f" && chmod 700 "$f" && %s && echo "$f"`, removeAfter(`"$f"`, AskpassLifetime))
+
+	stdout, stderr, err := conn.RunWithInput(ctx, cmd, strings.NewReader(program))
+	if err != nil {
+		return fmt.Errorf("unable to install askpass on %s: %w: %s", conn.Server.Host, err, strings.TrimSpace(stderr))
+	}
+	a.path = strings.TrimSpace(stdout)
+
+	return nil
+}
+
+// Uninstall removes the askpass program from the server.
+func (a *Askpass) Uninstall(ctx context.Context, conn *Conn) error {
+	if a.path == "" {
+		return nil
+	}
+
+	if _, stderr, err := conn.Run(ctx, "rm -f "+Quote(a.path)); err != nil {
+		return fmt.Errorf("unable to remove askpass from %s: %w: %s", conn.Server.Host, err, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// Command returns a shell command for the server the program is installed on
+// that runs cmd on host as user, authenticating with the password.
+func (a *Askpass) Command(user, host, cmd string) string {
+	path := a.path
+	if path == "" {
+		path = AskpassTemplate
+	}
+
+	return fmt.Sprintf("SSH_ASKPASS=%s SSH_ASKPASS_REQUIRE=force ssh -o PreferredAuthentications=keyboard-interactive,password -o NumberOfPasswordPrompts=1 -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o LogLevel=ERROR %s %s",
+		Quote(path), Quote(user+"@"+host), Quote(cmd))
+}
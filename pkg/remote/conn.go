@@ -0,0 +1,262 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/tvs/sshit"
+)
+
+// Conn is an authenticated SSH connection to a single server. A Conn is safe
+// for concurrent use; every command runs in its own session.
+type Conn struct {
+	// Server is the endpoint the connection was requested for. When the
+	// connection is tunneled through a jumpbox this is the far side of the
+	// tunnel, not the local listener.
+	Server sshit.Endpoint
+
+	client  *ssh.Client
+	timeout time.Duration
+}
+
+// ErrTimeout is returned when a command does not complete within the
+// connection's timeout.
+var ErrTimeout = sshit.ErrTimeout
+
+func dial(server, address sshit.Endpoint, cfg *ssh.ClientConfig) (*Conn, error) {
+	client, err := ssh.Dial("tcp", address.Address(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Server:  server,
+		client:  client,
+		timeout: cfg.Timeout,
+	}, nil
+}
+
+// Run executes cmd on the server and returns its stdout and stderr. The
+// command is abandoned if ctx is cancelled or the connection's timeout
+// elapses.
+func (c *Conn) Run(ctx context.Context, cmd string) (string, string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", "", err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := wait(ctx, session, cmd, c.timeout); err != nil {
+		return stdout.String(), stderr.String(), err
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+// RunWithOutput executes cmd on the server, streaming the command's standard
+// output to stdout, and returns its stderr. As with RunWithInput, the
+// connection's timeout is not applied but the command is abandoned if ctx is
+// cancelled.
+func (c *Conn) RunWithOutput(ctx context.Context, cmd string, stdout io.Writer) (string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdout = stdout
+	session.Stderr = &stderr
+
+	if err := wait(ctx, session, cmd, 0); err != nil {
+		return stderr.String(), err
+	}
+
+	return stderr.String(), nil
+}
+
+// RunWithStderr executes cmd on the server, streaming the command's standard
+// error to stderr, and returns its stdout. As with RunWithInput, the
+// connection's timeout is not applied but the command is abandoned if ctx is
+// cancelled.
+func (c *Conn) RunWithStderr(ctx context.Context, cmd string, stderr io.Writer) (string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = stderr
+
+	if err := wait(ctx, session, cmd, 0); err != nil {
+		return stdout.String(), err
+	}
+
+	return stdout.String(), nil
+}
+
+// RunWithInput executes cmd on the server, streaming stdin to the command's
+// standard input, and returns its stdout and stderr. The connection's timeout
+// is not applied as the duration depends on the size of the input, but the
+// command is abandoned if ctx is cancelled.
+func (c *Conn) RunWithInput(ctx context.Context, cmd string, stdin io.Reader) (string, string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", "", err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := wait(ctx, session, cmd, 0); err != nil {
+		return stdout.String(), stderr.String(), err
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+// CopyFrom sends size bytes read from r to target on the server using the SCP
+// protocol, creating it with the given mode. The copy is abandoned if ctx is
+// cancelled.
+func (c *Conn) CopyFrom(ctx context.Context, r io.Reader, size int64, mode fs.FileMode, target string) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// This might throw an error once we've run successfully, but we can ignore
+	// it. This at least ensures we clean up if we error out elsewhere.
+	defer w.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := session.Start("scp -t " + Quote(target)); err != nil {
+		return fmt.Errorf("unable to initiate SCP: %w", err)
+	}
+
+	if err := writeSCP(w, io.LimitReader(r, size), stdout, size, mode, target); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("unable to complete write: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to close write stream: %w", err)
+	}
+
+	if err := session.Wait(); err != nil {
+		return fmt.Errorf("error with remote SCP session: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying SSH connection.
+func (c *Conn) Close() error {
+	return c.client.Close()
+}
+
+// wait starts cmd on the session and waits for it to complete, for ctx to be
+// cancelled, or for the timeout to elapse. A zero timeout waits indefinitely.
+func wait(ctx context.Context, session *ssh.Session, cmd string, timeout time.Duration) error {
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	// Closing the session unblocks the waiting goroutine
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case err := <-done:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	case <-expired:
+		session.Close()
+		return ErrTimeout
+	}
+}
+
+// writeSCP sends the contents of reader over the writer using the SCP protocol
+// and returns an error if SCP issues one.
+func writeSCP(writer io.Writer, reader, stdout io.Reader, size int64, mode fs.FileMode, target string) error {
+	if _, err := fmt.Fprintf(writer, "C%04o %d %s\n", mode.Perm(), size, filepath.Base(target)); err != nil {
+		return err
+	}
+
+	if err := checkSCPResponse(stdout); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprint(writer, "\x00"); err != nil {
+		return err
+	}
+
+	return checkSCPResponse(stdout)
+}
+
+func checkSCPResponse(reader io.Reader) error {
+	buf := make([]byte, 1)
+	if _, err := reader.Read(buf); err != nil {
+		return err
+	}
+
+	if buf[0] == 0 {
+		return nil
+	}
+
+	// Warnings and errors are followed by a newline terminated message
+	var msg []byte
+	for {
+		if _, err := reader.Read(buf); err != nil || buf[0] == '\n' {
+			break
+		}
+		msg = append(msg, buf[0])
+	}
+
+	return fmt.Errorf("scp: %s", msg)
+}
@@ -0,0 +1,182 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/tvs/sshit"
+	"github.com/tvs/ultravisor/pkg/config"
+	"github.com/tvs/ultravisor/pkg/util/jumpbox"
+)
+
+// Manager opens and caches the tunnels and SSH connections used throughout an
+// operation. When a jumpbox is configured, a single jumpbox session is shared
+// by every tunnel, and each remote endpoint is tunneled and connected to at
+// most once. A Manager is safe for concurrent use.
+type Manager struct {
+	jumpbox      *sshit.Client
+	closeJumpbox func()
+	mu           sync.Mutex
+	tunnels      map[sshit.Endpoint]*tunnelEntry
+	conns        map[connKey]*connEntry
+	closed       bool
+}
+
+type tunnelEntry struct {
+	once   sync.Once
+	tunnel sshit.Tunnel
+	local  sshit.Endpoint
+	err    error
+}
+
+type connKey struct {
+	server sshit.Endpoint
+	user   string
+}
+
+type connEntry struct {
+	once sync.Once
+	conn *Conn
+	err  error
+}
+
+// NewManager returns a Manager, connecting to the jumpbox if one is supplied.
+// The Manager must be closed once the operation is finished.
+func NewManager(ctx context.Context, jumpboxConfig *config.SSHConfig) (*Manager, error) {
+	m := &Manager{
+		tunnels: map[sshit.Endpoint]*tunnelEntry{},
+		conns:   map[connKey]*connEntry{},
+	}
+
+	if jumpboxConfig != nil {
+		var err error
+		m.jumpbox, m.closeJumpbox, err = jumpbox.JumpboxClient(ctx, jumpboxConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Endpoint returns the endpoint to dial in order to reach remote. Without a
+// jumpbox this is remote itself; otherwise it is the local side of a forward
+// tunnel through the jumpbox, which is established on first use.
+func (m *Manager) Endpoint(ctx context.Context, remote sshit.Endpoint) (sshit.Endpoint, error) {
+	if m.jumpbox == nil {
+		return remote, nil
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return sshit.Endpoint{}, fmt.Errorf("connection manager is closed")
+	}
+	e, ok := m.tunnels[remote]
+	if !ok {
+		e = &tunnelEntry{}
+		m.tunnels[remote] = e
+	}
+	m.mu.Unlock()
+
+	e.once.Do(func() {
+		l := zerolog.Ctx(ctx)
+
+		tunnel := sshit.NewForwardTunnel(ctx,
+			sshit.Endpoint{Host: "localhost", Port: 0},
+			remote)
+
+		l.Debug().Str("remote", remote.Address()).Msg("establishing tunnel through jumpbox")
+		if err := tunnel.Bind(m.jumpbox); err != nil {
+			e.err = fmt.Errorf("unable to establish tunnel to %s: %w", remote.Address(), err)
+			return
+		}
+
+		e.tunnel = tunnel
+		e.local = sshit.Endpoint{Host: tunnel.Local().Host, Port: tunnel.Local().Port}
+	})
+
+	return e.local, e.err
+}
+
+// Conn returns an authenticated SSH connection to server, dialing it on first
+// use. Subsequent calls for the same server and user return the same
+// connection, regardless of the supplied config.
+func (m *Manager) Conn(ctx context.Context, server sshit.Endpoint, cfg *ssh.ClientConfig) (*Conn, error) {
+	key := connKey{server: server, user: cfg.User}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("connection manager is closed")
+	}
+	e, ok := m.conns[key]
+	if !ok {
+		e = &connEntry{}
+		m.conns[key] = e
+	}
+	m.mu.Unlock()
+
+	e.once.Do(func() {
+		l := zerolog.Ctx(ctx)
+
+		address, err := m.Endpoint(ctx, server)
+		if err != nil {
+			e.err = err
+			return
+		}
+
+		l.Debug().Str("server", server.Address()).Str("address", address.Address()).Msg("initiating SSH connection")
+		e.conn, e.err = dial(server, address, cfg)
+		if e.err != nil {
+			e.err = fmt.Errorf("unable to initiate SSH connection to %s: %w", server.Address(), e.err)
+		}
+	})
+
+	return e.conn, e.err
+}
+
+// Close closes every connection and tunnel opened by the Manager, followed by
+// the jumpbox session.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	var errs []error
+	for k, e := range m.conns {
+		if e.conn == nil {
+			continue
+		}
+
+		if err := e.conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, fmt.Errorf("unable to close SSH connection to %s: %w", k.server.Address(), err))
+		}
+	}
+
+	for remote, e := range m.tunnels {
+		if e.tunnel == nil {
+			continue
+		}
+
+		if tErr := e.tunnel.Close(); tErr != nil {
+			errs = append(errs, fmt.Errorf("unable to close tunnel to %s: %w", remote.Address(), errors.Join(tErr...)))
+		}
+	}
+
+	if m.closeJumpbox != nil {
+		m.closeJumpbox()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,24 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Quote quotes s for use as a single argument in a POSIX shell command.
+func Quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,+") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// removeAfter returns a shell command removing file in the background once d
+// has passed, outliving the session that ran it. file is a shell word, so it
+// may expand a variable.
+func removeAfter(file string, d time.Duration) string {
+	rm := fmt.Sprintf(`sleep %d; rm -f "$0"`, int(d.Seconds()))
+	return fmt.Sprintf("(nohup sh -c %s %s </dev/null >/dev/null 2>&1 &)", Quote(rm), file)
+}
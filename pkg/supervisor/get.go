@@ -2,10 +2,10 @@ package supervisor
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/tvs/sshit"
@@ -15,9 +15,10 @@ import (
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/tvs/ultravisor/pkg/config"
-	"github.com/tvs/ultravisor/pkg/util/jumpbox"
+	"github.com/tvs/ultravisor/pkg/remote"
 )
 
 type SupervisorInfo struct {
@@ -29,25 +30,23 @@ type SupervisorInfo struct {
 func Info(ctx context.Context) (*SupervisorInfo, error) {
 	c := config.Ctx(ctx)
 
-	var j *sshit.Client
-	if c.JumpboxConfig != nil {
-		var (
-			cleanup func()
-			err     error
-		)
+	m, err := remote.NewManager(ctx, c.JumpboxConfig)
+	if err != nil {
+		return nil, err
+	}
 
-		j, cleanup, err = jumpbox.JumpboxClient(ctx, c.JumpboxConfig)
-		if err != nil {
-			return nil, err
+	defer func() {
+		if err := m.Close(); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("unable to close remote connections")
 		}
+	}()
 
-		defer cleanup()
-	}
-
-	return InfoWithJumpbox(ctx, j)
+	return InfoWithManager(ctx, m)
 }
 
-func InfoWithJumpbox(ctx context.Context, jumpbox *sshit.Client) (*SupervisorInfo, error) {
+// InfoWithManager retrieves the Supervisor info, opening any tunnels and
+// connections through m so they may be reused by the caller.
+func InfoWithManager(ctx context.Context, m *remote.Manager) (*SupervisorInfo, error) {
 	l := zerolog.Ctx(ctx)
 	c := config.Ctx(ctx)
 
@@ -58,13 +57,13 @@ func InfoWithJumpbox(ctx context.Context, jumpbox *sshit.Client) (*SupervisorInf
 		return nil, err
 	}
 
-	vms, err := getSupervisorVMs(ctx, c, jumpbox)
+	vms, err := getSupervisorVMs(ctx, c, m)
 	if err != nil {
 		l.Error().Err(err).Msg("unable to retrieve Supervisor VMs")
 		return nil, fmt.Errorf("unable to retrieve Supervisor VMs: %w", err)
 	}
 
-	controlPlane, password, err := getSupervisorCredentials(ctx, c, jumpbox)
+	controlPlane, password, err := getSupervisorCredentials(ctx, c, m)
 	if err != nil {
 		l.Error().Err(err).Msg("unable to retrieve Supervisor credentials")
 		return nil, fmt.Errorf("unable to retrieve Supervisor credentials: %w", err)
@@ -77,6 +76,31 @@ func InfoWithJumpbox(ctx context.Context, jumpbox *sshit.Client) (*SupervisorInf
 	}, nil
 }
 
+// VMClientConfig creates an SSH ClientConfig for connecting to a Supervisor
+// control plane VM as root with the Supervisor password.
+func VMClientConfig(c *config.Config, password string) *ssh.ClientConfig {
+	var timeout time.Duration
+	if c.VCenterConfig.SSH.Timeout == nil {
+		timeout = 60 * time.Second
+	} else {
+		timeout = c.VCenterConfig.SSH.Timeout.Duration
+	}
+
+	return &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		// TODO(tvs): Separate timeout for Supervisor
+		Timeout: timeout,
+	}
+}
+
+// VMEndpoint returns the SSH endpoint of a Supervisor control plane VM.
+func VMEndpoint(vm string) sshit.Endpoint {
+	// TODO(tvs): Configurable ports for Supervisor VMs?
+	return sshit.Endpoint{Host: vm, Port: 22}
+}
+
 // TODO(tvs): Figure out a nice way to send back and log each invalidity
 // errors.Join() doesn't work well with default zerolog for readability
 // and log.Errs() doesn't use the colorized error handler
@@ -127,33 +151,13 @@ func validateVCenterConfig(c *config.VCenterConfig) error {
 	return validateSSHConfig(c.SSH)
 }
 
-func getSupervisorVMs(ctx context.Context, c *config.Config, jumpbox *sshit.Client) (_ []string, err error) {
+func getSupervisorVMs(ctx context.Context, c *config.Config, m *remote.Manager) (_ []string, err error) {
 	l := zerolog.Ctx(ctx)
 
-	// Start tunnel if we need it
-	var endpoint sshit.Endpoint
-	if c.JumpboxConfig != nil {
-		tunnel := sshit.NewForwardTunnel(ctx,
-			sshit.Endpoint{Host: "localhost", Port: 0},
-			sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: 443})
-
-		if err = tunnel.Bind(jumpbox); err != nil {
-			l.Error().Err(err).Msg("unable to establish tunnel to vCenter")
-			return nil, fmt.Errorf("unable to establish tunnel to vCenter: %w", err)
-		}
-
-		defer func() {
-			if tErr := tunnel.Close(); tErr != nil {
-				l.Error().Errs("err", tErr).Msg("unable to close tunnel to vCenter")
-				if err == nil {
-					err = errors.Join(tErr...)
-				}
-			}
-		}()
-
-		endpoint = sshit.Endpoint{Host: tunnel.Local().Host, Port: tunnel.Local().Port}
-	} else {
-		endpoint = sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: 443}
+	endpoint, err := m.Endpoint(ctx, sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: 443})
+	if err != nil {
+		l.Error().Err(err).Msg("unable to establish tunnel to vCenter")
+		return nil, fmt.Errorf("unable to establish tunnel to vCenter: %w", err)
 	}
 
 	// Set up a session so we can log out once we're done
@@ -182,8 +186,8 @@ func getSupervisorVMs(ctx context.Context, c *config.Config, jumpbox *sshit.Clie
 		}
 	}()
 
-	m := view.NewManager(client)
-	v, err := m.CreateContainerView(ctx, client.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	viewMgr := view.NewManager(client)
+	v, err := viewMgr.CreateContainerView(ctx, client.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
 	if err != nil {
 		return nil, err
 	}
@@ -261,62 +265,21 @@ func vcSession(endpoint sshit.Endpoint, username, password string) (*cache.Sessi
 	}, nil
 }
 
-func getSupervisorCredentials(ctx context.Context, c *config.Config, jumpbox *sshit.Client) (controlPlane, password string, err error) {
+func getSupervisorCredentials(ctx context.Context, c *config.Config, m *remote.Manager) (controlPlane, password string, err error) {
 	l := zerolog.Ctx(ctx)
 
-	// TODO(tvs): Reuse jumpbox ssh client
-	// Start tunnel if we need it
-	var endpoint sshit.Endpoint
-	if c.JumpboxConfig != nil {
-		tunnel := sshit.NewForwardTunnel(ctx,
-			sshit.Endpoint{Host: "localhost", Port: 0},
-			sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: 22})
-
-		if err = tunnel.Bind(jumpbox); err != nil {
-			l.Error().Err(err).Msg("unable to establish tunnel to vCenter")
-			return "", "", fmt.Errorf("unable to establish tunnel to vCenter: %w", err)
-		}
-
-		defer func() {
-			if tErr := tunnel.Close(); tErr != nil {
-				l.Error().Errs("err", tErr).Msg("unable to close tunnel to vCenter")
-				if err == nil {
-					err = errors.Join(tErr...)
-				}
-			}
-		}()
-
-		endpoint = sshit.Endpoint{Host: tunnel.Local().Host, Port: tunnel.Local().Port}
-	} else {
-		endpoint = sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: *c.VCenterConfig.SSH.Port}
-	}
-
 	cfg, err := c.VCenterConfig.SSH.ClientConfig()
 	if err != nil {
 		return "", "", err
 	}
 
-	ssh := sshit.Client{
-		Config: cfg,
-		Server: endpoint,
-	}
-
-	if err := ssh.Connect(ctx); err != nil {
+	conn, err := m.Conn(ctx, sshit.Endpoint{Host: c.VCenterConfig.SSH.Host, Port: *c.VCenterConfig.SSH.Port}, cfg)
+	if err != nil {
 		l.Error().Err(err).Msg("unable to initiate SSH connection")
 		return "", "", fmt.Errorf("unable to initiate SSH connection: %w", err)
 	}
 
-	defer func() {
-		if tErr := ssh.Close(); tErr != nil {
-			l.Error().Err(tErr).Msg("unable to close SSH session")
-			if err == nil {
-				err = fmt.Errorf("unable to close SSH session: %w", tErr)
-			}
-		}
-		ssh.Close()
-	}()
-
-	stdout, stderr, err := ssh.Run("/usr/lib/vmware-wcp/decryptK8Pwd.py")
+	stdout, stderr, err := conn.Run(ctx, "/usr/lib/vmware-wcp/decryptK8Pwd.py")
 	if err != nil {
 		l.Error().Err(err).Str("stderr", stderr).Msg("unable to execute decryptK8Pwd")
 		return "", "", fmt.Errorf("unable to execute decryptK8Pwd: %w", err)
@@ -0,0 +1,18 @@
+package output
+
+import "strings"
+
+// Digest truncates a digest to a length suitable for tables, e.g.
+// "sha256:5b0bcabd1ed2", or "-" if it is empty.
+func Digest(digest string) string {
+	if digest == "" {
+		return "-"
+	}
+
+	alg, hex, _ := strings.Cut(digest, ":")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+
+	return alg + ":" + hex
+}
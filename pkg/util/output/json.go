@@ -0,0 +1,23 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JSON writes v to w as indented JSON. HTML characters are not escaped so
+// passwords and references are printed verbatim.
+func JSON(w io.Writer, v any) error {
+	bw := bufio.NewWriter(w)
+
+	e := json.NewEncoder(bw)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+
+	if err := e.Encode(v); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
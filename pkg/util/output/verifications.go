@@ -0,0 +1,46 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// VerificationRow is a single reference checked on a VM.
+type VerificationRow struct {
+	// Origin is where the reference came from, such as a container file or
+	// a source VM.
+	Origin    string
+	VM        string
+	Reference string
+	Expected  string
+	Actual    string
+	Status    string
+	Pinned    bool
+}
+
+// Verifications writes a table of verification results, with the origin
+// column headed by origin and digests shortened. The pinned column is only
+// written if pins is set.
+func Verifications(w io.Writer, origin string, rows []VerificationRow, pins bool) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := origin + "\tVM\tREFERENCE\tEXPECTED\tACTUAL\tSTATUS"
+	if pins {
+		header += "\tPINNED"
+	}
+	fmt.Fprintln(tw, header)
+
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s", r.Origin, r.VM, r.Reference, Digest(r.Expected), Digest(r.Actual), r.Status)
+		if pins {
+			fmt.Fprintf(tw, "\t%t", r.Pinned)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
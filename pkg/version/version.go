@@ -0,0 +1,45 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Info describes the build of the running binary.
+type Info struct {
+	// Version of the binary.
+	Version string `json:"version,omitempty"`
+	// Revision is a git commit.
+	Revision string `json:"revision,omitempty"`
+	// GoOs holds the name of the OS the binary was built on.
+	GoOs string `json:"goOs,omitempty"`
+	// GoArch holds the name of the architecture the binary was built on.
+	GoArch string `json:"goArch,omitempty"`
+	// GoVersion holds the version of Go used to build the binary.
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Revision:  "unknown",
+		GoOs:      runtime.GOOS,
+		GoArch:    runtime.GOARCH,
+		GoVersion: runtime.Version(),
+	}
+
+	b, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Version = b.Main.Version
+	for _, setting := range b.Settings {
+		if setting.Key == "vcs.revision" {
+			info.Revision = setting.Value
+			break
+		}
+	}
+
+	return info
+}